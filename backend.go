@@ -2,18 +2,33 @@ package gitops_terraform
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/admission"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/directives"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/drift"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/lease"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/metrics"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/notifications"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/pgp"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/plans"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/policies"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/resource_policy"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/retry"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/schedule"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/terraform"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/vault_client"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/webhook"
 )
 
 type backend struct {
@@ -22,9 +37,25 @@ type backend struct {
 	// Vault token expire time stored in memory (not in storage)
 	vaultTokenTTL         *vault_client.TokenTTL
 	vaultTokenExpireMutex sync.RWMutex
+	// Set once the token is notified as expiring, reset when its TTL is long enough again
+	vaultTokenExpiringNotified bool
 
-	// Guard to prevent concurrent execution of processGit
-	processGitCASGuard *uint32
+	// Guards to prevent concurrent execution of processGit, one per workspace
+	processGitCASGuards      map[string]*uint32
+	processGitCASGuardsMutex sync.Mutex
+
+	// The processGit runs in progress by workspace
+	currentRuns      map[string]*currentRun
+	currentRunsMutex sync.Mutex
+
+	// Counters exposed by the metrics path, kept in memory
+	metrics *metrics.Collector
+
+	// Owner of the run lease acquired by this plugin instance
+	nodeID string
+	// Notified by invalidate when the run lease of the workspace is changed by another node
+	runLeaseChecks      map[string]chan struct{}
+	runLeaseChecksMutex sync.Mutex
 }
 
 var _ logical.Factory = Factory
@@ -47,8 +78,17 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 }
 
 func newBackend(c *logical.BackendConfig) (*backend, error) {
+	nodeID, err := newNodeID()
+	if err != nil {
+		return nil, err
+	}
+
 	b := &backend{
-		processGitCASGuard: new(uint32),
+		processGitCASGuards: map[string]*uint32{},
+		currentRuns:         map[string]*currentRun{},
+		metrics:             metrics.NewCollector(),
+		nodeID:              nodeID,
+		runLeaseChecks:      map[string]chan struct{}{},
 	}
 
 	baseBackend := &framework.Backend{
@@ -58,10 +98,23 @@ func newBackend(c *logical.BackendConfig) (*backend, error) {
 		PeriodicFunc: func(ctx context.Context, req *logical.Request) error {
 			return b.PeriodicTask(req.Storage)
 		},
+		InitializeFunc: b.initialize,
+		Clean:          b.clean,
+		Invalidate:     b.invalidate,
 		PathsSpecial: &logical.Paths{
+			Unauthenticated: []string{
+				"webhook/*",
+				"workspaces/+/webhook/*",
+			},
 			SealWrapStorage: []string{
 				vault_client.StorageKeyConfiguration,
 				git.StorageKeyConfigurationGitCredential,
+				webhook.StorageKeyPrefixSecret,
+				admission.StorageKeyConfiguration,
+				notifications.StorageKeyPrefixChannel,
+				// Plans hold values of the resources, the storage of a named workspace holds its plans
+				plans.StorageKeyPrefixPlan,
+				storageKeyPrefixWorkspace,
 			},
 		},
 	}
@@ -72,17 +125,27 @@ func newBackend(c *logical.BackendConfig) (*backend, error) {
 		terraform.Paths(baseBackend),
 		git.CredentialsPaths(),
 		pgp.Paths(),
-		[]*framework.Path{
-			{
-				Pattern: "status",
-				Operations: map[logical.Operation]framework.OperationHandler{
-					logical.ReadOperation: &framework.PathOperation{
-						Callback: b.pathStatusRead,
-						Summary:  "Read the current status",
-					},
-				},
-			},
-		},
+		webhook.Paths(baseBackend),
+		retry.Paths(baseBackend),
+		// must precede runs.Paths: runs/<run_id> matches runs/retry too
+		retryPaths(b),
+		cancelPaths(b),
+		runs.Paths(baseBackend),
+		schedule.Paths(baseBackend),
+		drift.Paths(baseBackend),
+		resource_policy.Paths(baseBackend),
+		policies.Paths(baseBackend),
+		admission.Paths(baseBackend),
+		directives.Paths(baseBackend),
+		notifications.Paths(baseBackend),
+		syncPaths(b),
+		pausePaths(b),
+		rollbackPaths(b),
+		plansPaths(b),
+		webhookPaths(b),
+		statusPaths(b),
+		metricsPaths(b),
+		workspacePaths(b, baseBackend),
 	)
 
 	b.Backend = baseBackend
@@ -90,6 +153,36 @@ func newBackend(c *logical.BackendConfig) (*backend, error) {
 	return b, nil
 }
 
+// errorClassCancelled is the class of the error of a cancelled run, see retry.ErrorClass for others
+const errorClassCancelled = "cancelled"
+
+func statusPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "status",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathStatusRead,
+					Summary:  "Read the current status",
+				},
+			},
+		},
+	}
+}
+
+// processGitCASGuard returns the guard of the workspace
+func (b *backend) processGitCASGuard(workspace string) *uint32 {
+	b.processGitCASGuardsMutex.Lock()
+	defer b.processGitCASGuardsMutex.Unlock()
+
+	guard, ok := b.processGitCASGuards[workspace]
+	if !ok {
+		guard = new(uint32)
+		b.processGitCASGuards[workspace] = guard
+	}
+	return guard
+}
+
 func (b *backend) pathStatusRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	b.Logger().Debug("Reading git repository configuration")
 
@@ -111,10 +204,165 @@ func (b *backend) pathStatusRead(ctx context.Context, req *logical.Request, data
 		last_run = time.Unix(lastRunTimestamp, 0).Format(time.RFC3339)
 	}
 
+	lastRunID, err := util.GetString(ctx, req.Storage, storageKeyLastRunID)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get run id: %s", err), nil
+	}
+
+	var lastRun *runs.Run
+	if lastRunID != "" {
+		if lastRun, err = runs.GetRun(ctx, req.Storage, lastRunID); err != nil {
+			return logical.ErrorResponse("Unable to get run: %s", err), nil
+		}
+	}
+
+	// The legacy free-form status is kept for compatibility, the fields below describe the same state
 	responseData := map[string]interface{}{
-		"status":   status,
-		"last_run": last_run,
+		"status":      status,
+		"last_run":    last_run,
+		"last_run_id": lastRunID,
+	}
+
+	running := atomic.LoadUint32(b.processGitCASGuard(requestWorkspace(data))) != 0
+	responseData["running"] = running
+	responseData["phase"] = ""
+	responseData["current_commit"] = ""
+	if running && lastRun != nil && lastRun.Status == runs.StatusRunning {
+		responseData["phase"] = lastRun.Phase
+		responseData["current_commit"] = lastRun.CommitHash
+	}
+
+	runLease, err := lease.Get(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get run lease: %s", err), nil
+	}
+	if runLease != nil {
+		responseData["lease_owner"] = runLease.Owner
+		responseData["lease_run_id"] = runLease.RunID
+		responseData["lease_expires_at"] = runLease.ExpiresAt.Format(time.RFC3339)
+	}
+
+	// Repository which is not configured is never polled
+	responseData["next_poll_at"] = ""
+	if gitConfig, err := git_repository.GetConfig(ctx, req.Storage, b.Logger()); err == nil && lastRunTimestamp > 0 {
+		responseData["next_poll_at"] = time.Unix(lastRunTimestamp, 0).Add(gitConfig.GitPollPeriod).Format(time.RFC3339)
+	}
+
+	var headCommit *HeadCommit
+	if err := util.GetJSON(ctx, req.Storage, storageKeyHeadCommit, &headCommit); err != nil {
+		return logical.ErrorResponse("Unable to get HEAD commit: %s", err), nil
+	}
+	if headCommit != nil {
+		responseData["head_commit"] = headCommit.CommitHash
+		responseData["head_commit_date"] = headCommit.CommitDate.Format(time.RFC3339)
+		responseData["head_commit_signed"] = headCommit.Signed
+		responseData["head_seen_at"] = headCommit.SeenAt.Format(time.RFC3339)
+	} else {
+		responseData["head_commit"] = ""
+		responseData["head_commit_date"] = ""
+		responseData["head_commit_signed"] = false
+		responseData["head_seen_at"] = ""
+	}
+	var lastWebhookEvent *WebhookEvent
+	if err := util.GetJSON(ctx, req.Storage, storageKeyLastWebhookEvent, &lastWebhookEvent); err != nil {
+		return logical.ErrorResponse("Unable to get webhook event: %s", err), nil
+	}
+	if lastWebhookEvent != nil {
+		responseData["last_webhook_event"] = webhookEventToMap(lastWebhookEvent)
+	}
+
+	pauseState, err := getPauseState(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get pause state: %s", err), nil
+	}
+	responseData["paused"] = pauseState != nil
+	if pauseState != nil {
+		responseData["pause_reason"] = pauseState.Reason
+		responseData["paused_by"] = pauseState.RequestedBy
+		responseData["paused_at"] = pauseState.PausedAt.Format(time.RFC3339)
+	}
+
+	var lastApplySummary *runs.PlanSummary
+	if err := util.GetJSON(ctx, req.Storage, storageKeyLastApplySummary, &lastApplySummary); err != nil {
+		return logical.ErrorResponse("Unable to get apply summary: %s", err), nil
+	}
+	if lastApplySummary != nil {
+		responseData["last_apply_summary"] = lastApplySummary
+		responseData["last_apply_summary_text"] = lastApplySummary.String()
+	}
+
+	driftResult, err := drift.GetResult(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get drift result: %s", err), nil
+	}
+	if driftResult != nil {
+		responseData["drift"] = driftResultToMap(driftResult)
+	}
+
+	rollbackOverride, err := getRollbackOverride(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get rollback override: %s", err), nil
+	}
+	if rollbackOverride != nil {
+		responseData["rollback_commit"] = rollbackOverride.CommitHash
+		responseData["rollback_branch_head"] = rollbackOverride.BranchHeadHash
+		responseData["rollback_by"] = rollbackOverride.RequestedBy
+		responseData["rollback_at"] = rollbackOverride.RequestedAt.Format(time.RFC3339)
+	}
+
+	failure, err := retry.GetFailure(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get commit failure: %s", err), nil
+	}
+	lastError, lastErrorClass, consecutiveFailures := "", "", 0
+	switch {
+	case lastRun != nil && lastRun.Status == runs.StatusCancelled:
+		lastError, lastErrorClass = lastRun.Error, errorClassCancelled
+	case lastRun != nil && lastRun.Status == runs.StatusFailed:
+		lastError, lastErrorClass = lastRun.Error, retry.ErrorClass(errors.New(lastRun.Error))
+	case failure != nil:
+		lastError, lastErrorClass = failure.LastError, retry.ErrorClassTransient
+		if failure.Permanent {
+			lastErrorClass = retry.ErrorClassPermanent
+		}
+	}
+	if failure != nil {
+		consecutiveFailures = failure.Attempts
+	}
+	responseData["last_error"] = lastError
+	responseData["last_error_class"] = lastErrorClass
+	responseData["consecutive_failures"] = consecutiveFailures
+	if failure != nil {
+		responseData["failed_commit"] = failure.CommitHash
+		responseData["failed_attempts"] = failure.Attempts
+		responseData["failed_commit_error"] = failure.LastError
+		responseData["quarantined"] = failure.Quarantined
+		if !failure.NextAttemptAt.IsZero() {
+			responseData["next_attempt_at"] = failure.NextAttemptAt.Format(time.RFC3339)
+		}
+	}
+
+	pendingCommit, err := getPendingCommit(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get pending commit: %s", err), nil
 	}
+	if pendingCommit != nil {
+		responseData["pending_commit"] = pendingCommit.CommitHash
+		responseData["pending_commit_date"] = pendingCommit.CommitDate.Format(time.RFC3339)
+		if !pendingCommit.DeferredUntil.IsZero() {
+			responseData["deferred_until"] = pendingCommit.DeferredUntil.Format(time.RFC3339)
+		}
+	}
+
+	lastAppliedCommit, err := getLastAppliedCommit(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get last applied commit: %s", err), nil
+	}
+	responseData["last_applied_commit"] = ""
+	if lastAppliedCommit != nil {
+		responseData["last_applied_commit"] = lastAppliedCommit.CommitHash
+	}
+
 	if lastFinishedCommit != nil {
 		responseData["last_finished_commit"] = lastFinishedCommit.CommitHash
 		responseData["last_finished_commit_date"] = lastFinishedCommit.CommitDate.Format(time.RFC3339)
@@ -175,6 +423,7 @@ func (b *backend) checkAndUpdateVaultTokenExpireTime(ctx context.Context, storag
 	// Check if token has already expired
 	if ttl.ExpireTime.Before(time.Now()) {
 		b.Logger().Warn(fmt.Sprintf("Token has already expired (expired at: %v), cannot renew", ttl.ExpireTime))
+		b.notifyTokenExpiring(storage, fmt.Sprintf("Vault token expired at %s", ttl.ExpireTime.Format(time.RFC3339)))
 		return nil
 	}
 
@@ -188,14 +437,21 @@ func (b *backend) checkAndUpdateVaultTokenExpireTime(ctx context.Context, storag
 		// Renew token using vault_client function
 		newTTL, err := vault_client.RenewTokenSelf(ctx, vaultConfig, b.Logger())
 		if err != nil {
+			b.notifyTokenExpiring(storage, fmt.Sprintf("Vault token expires at %s and can not be renewed: %s", ttl.ExpireTime.Format(time.RFC3339), err))
 			return fmt.Errorf("unable to renew token: %w", err)
 		}
 
+		// The token reached its max TTL
+		if time.Until(newTTL.ExpireTime) < oneDay {
+			b.notifyTokenExpiring(storage, fmt.Sprintf("Vault token expires at %s and can not be renewed further", newTTL.ExpireTime.Format(time.RFC3339)))
+		}
+
 		// Update expire time in backend
 		b.updateVaultTokenTTL(newTTL)
 		b.Logger().Info(fmt.Sprintf("Token renewed successfully, new expire time: %v", newTTL.ExpireTime))
 	} else {
 		b.Logger().Debug(fmt.Sprintf("Token expire time is OK (remaining: %v)", remainingTime))
+		b.resetTokenExpiringNotification()
 	}
 
 	return nil
@@ -0,0 +1,135 @@
+package gitops_terraform
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/terraform"
+)
+
+// cleanupTimeout limits waiting for the cancelled run on plugin unload:
+// terraform gets the grace period to stop, then the state is saved
+const cleanupTimeout = terraform.CancelGracePeriod + 30*time.Second
+
+// currentRun is the processGit run in progress
+type currentRun struct {
+	id        string
+	workspace string
+	cancel    context.CancelFunc
+	// closed when the run is finished
+	done chan struct{}
+}
+
+// beginRun registers the run of the workspace and returns its context, finish must be called when the run is finished
+func (b *backend) beginRun(workspace, runID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &currentRun{
+		id:        runID,
+		workspace: workspace,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	b.currentRunsMutex.Lock()
+	b.currentRuns[workspace] = run
+	b.currentRunsMutex.Unlock()
+
+	return ctx, func() {
+		cancel()
+
+		b.currentRunsMutex.Lock()
+		delete(b.currentRuns, workspace)
+		b.currentRunsMutex.Unlock()
+
+		close(run.done)
+	}
+}
+
+// cancelCurrentRun cancels the run of the workspace in progress, returns nil if there is no run
+func (b *backend) cancelCurrentRun(workspace string) *currentRun {
+	b.currentRunsMutex.Lock()
+	defer b.currentRunsMutex.Unlock()
+
+	run := b.currentRuns[workspace]
+	if run == nil {
+		return nil
+	}
+	run.cancel()
+
+	return run
+}
+
+// clean is called on plugin unload: runs in progress are cancelled and waited for,
+// so the terraform state is saved and the temporary directory is removed
+func (b *backend) clean(ctx context.Context) {
+	b.currentRunsMutex.Lock()
+	var runsInProgress []*currentRun
+	for _, run := range b.currentRuns {
+		run.cancel()
+		runsInProgress = append(runsInProgress, run)
+	}
+	b.currentRunsMutex.Unlock()
+
+	deadline := time.After(cleanupTimeout)
+	for _, run := range runsInProgress {
+		b.Logger().Info("Waiting for the cancelled run to finish", "runID", run.id, "workspace", run.workspace)
+
+		select {
+		case <-run.done:
+			b.Logger().Info("Cancelled run finished", "runID", run.id, "workspace", run.workspace)
+		case <-deadline:
+			b.Logger().Warn("Cancelled run did not finish in time", "runID", run.id, "workspace", run.workspace)
+			return
+		case <-ctx.Done():
+			b.Logger().Warn("Cancelled run did not finish before cleanup deadline", "runID", run.id, "workspace", run.workspace)
+			return
+		}
+	}
+}
+
+func cancelPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "^runs/current/cancel/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathCurrentRunCancelWrite,
+					Summary:  "Cancel the run in progress.",
+				},
+			},
+			HelpSynopsis:    cancelHelpSyn,
+			HelpDescription: cancelHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathCurrentRunCancelWrite(_ context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	run := b.cancelCurrentRun(requestWorkspace(data))
+	if run == nil {
+		return logical.ErrorResponse("No run in progress"), nil
+	}
+
+	b.Logger().Info("Run cancelled", "runID", run.id, "workspace", run.workspace, "requestedBy", req.DisplayName)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"run_id": run.id,
+		},
+	}, nil
+}
+
+const (
+	cancelHelpSyn = `
+Cancel the run in progress.
+`
+	cancelHelpDesc = `
+Terraform receives SIGINT and is killed if it does not stop within the grace
+period. The terraform state is saved anyway. The run is recorded as cancelled
+and the commit is not quarantined: it is processed again by the next run.
+The run of a named workspace is cancelled at
+workspaces/<workspace>/runs/current/cancel.
+`
+)
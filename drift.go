@@ -0,0 +1,116 @@
+package gitops_terraform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/drift"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/lease"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/notifications"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+// checkDrift plans the last applied commit again when drift check is due,
+// records the changed resources and applies the commit if remediation is enabled
+func (b *backend) checkDrift(ctx context.Context, storage logical.Storage, tracker *runTracker, opts processGitOptions, lastAppliedCommit *git_repository.CommitInfo) error {
+	if lastAppliedCommit == nil {
+		return nil
+	}
+
+	config, err := drift.GetConfig(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to get drift configuration: %w", err)
+	}
+
+	due, err := drift.IsCheckDue(ctx, storage, config, systemClock.Now())
+	if err != nil {
+		return fmt.Errorf("unable to check drift period: %w", err)
+	}
+	if !due {
+		return nil
+	}
+
+	b.Logger().Debug("Checking drift", "commitHash", lastAppliedCommit.CommitHash)
+	tracker.SetCommit(lastAppliedCommit)
+
+	// Plan refreshes and saves terraform state, so it runs under the lease like apply
+	var changes []runs.ResourceChange
+	err = b.withRunLease(ctx, storage, opts.Workspace, opts.RunID, func(ctx context.Context) error {
+		changes, err = b.planCommit(ctx, storage, lastAppliedCommit.CommitHash, tracker)
+		return err
+	})
+	var heldErr *lease.HeldError
+	var lostErr *lease.LostError
+	switch {
+	case errors.As(err, &heldErr):
+		b.Logger().Info("Run lease is held by another node, drift check is skipped", "owner", heldErr.Lease.Owner)
+		tracker.SetStatus(runs.StatusLeaseHeld)
+		return nil
+	case errors.As(err, &lostErr):
+		b.Logger().Warn("Run lease is lost, drift check is not finished", "error", err)
+		tracker.SetStatus(runs.StatusLeaseHeld)
+		return nil
+	case err != nil:
+		return fmt.Errorf("checking drift of commit %q: %w", lastAppliedCommit.CommitHash, err)
+	}
+	tracker.SetDriftChanges(changes)
+
+	result := &drift.Result{
+		CommitHash: lastAppliedCommit.CommitHash,
+		CheckedAt:  systemClock.Now(),
+		Changes:    changes,
+	}
+
+	if len(changes) == 0 {
+		b.Logger().Debug("No drift detected", "commitHash", lastAppliedCommit.CommitHash)
+		return drift.PutResult(ctx, storage, result)
+	}
+
+	b.Logger().Warn("Drift detected", "commitHash", lastAppliedCommit.CommitHash, "changedResources", len(changes))
+
+	if config.Remediation {
+		applied, err := b.applyCommit(ctx, storage, tracker, opts, lastAppliedCommit)
+		result.Remediated = applied && err == nil
+		if putErr := drift.PutResult(ctx, storage, result); putErr != nil {
+			b.Logger().Warn(fmt.Sprintf("Unable to store drift result: %v", putErr))
+		}
+		return err
+	}
+
+	tracker.SetStatus(runs.StatusDriftDetected)
+	b.sendRunEvent(tracker, eventTypeDriftDetected, map[string]interface{}{
+		"changed_resources": len(changes),
+	})
+	b.notifyRun(tracker, notifications.EventDrift, fmt.Sprintf("%d resources changed outside of the repository", len(changes)))
+	if err := drift.PutResult(ctx, storage, result); err != nil {
+		return fmt.Errorf("unable to store drift result: %w", err)
+	}
+	if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Drift detected in commit %q: %d resources changed outside of the repository", lastAppliedCommit.CommitHash, len(changes))); err != nil {
+		return fmt.Errorf("unable to store process status commit: %w", err)
+	}
+
+	return nil
+}
+
+// driftResultToMap converts drift result to status response data
+func driftResultToMap(result *drift.Result) map[string]interface{} {
+	changes := make([]map[string]interface{}, 0, len(result.Changes))
+	for _, change := range result.Changes {
+		changes = append(changes, map[string]interface{}{
+			"address": change.Address,
+			"actions": change.Actions,
+		})
+	}
+
+	return map[string]interface{}{
+		"commit_hash": result.CommitHash,
+		"checked_at":  result.CheckedAt.Format(time.RFC3339),
+		"changes":     changes,
+		"remediated":  result.Remediated,
+	}
+}
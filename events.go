@@ -0,0 +1,74 @@
+package gitops_terraform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/retry"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+// Types of events published to the Vault event subsystem
+const (
+	eventTypeRunStarted     = "gitops-terraform/run-started"
+	eventTypeRunSucceeded   = "gitops-terraform/run-succeeded"
+	eventTypeRunFailed      = "gitops-terraform/run-failed"
+	eventTypeCommitRejected = "gitops-terraform/commit-rejected"
+	eventTypeDriftDetected  = "gitops-terraform/drift-detected"
+)
+
+// sendEvent publishes the event to the Vault event subsystem, nothing is sent if events are not enabled
+func (b *backend) sendEvent(ctx context.Context, eventType string, metadata map[string]interface{}) {
+	event, err := logical.NewEvent()
+	if err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to create event %q: %v", eventType, err))
+		return
+	}
+
+	event.Metadata, err = structpb.NewStruct(metadata)
+	if err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to create metadata of event %q: %v", eventType, err))
+		return
+	}
+
+	if err := b.SendEvent(ctx, logical.EventType(eventType), event); err != nil && !errors.Is(err, framework.ErrNoEvents) {
+		b.Logger().Warn(fmt.Sprintf("Unable to send event %q: %v", eventType, err))
+	}
+}
+
+// sendRunEvent publishes the event about the run with its commit
+func (b *backend) sendRunEvent(tracker *runTracker, eventType string, metadata map[string]interface{}) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["run_id"] = tracker.run.ID
+	metadata["workspace"] = tracker.run.Workspace
+	metadata["trigger"] = tracker.run.Trigger
+	metadata["commit_hash"] = tracker.run.CommitHash
+
+	b.sendEvent(tracker.ctx, eventType, metadata)
+}
+
+// sendRunFinishedEvent publishes the result of the finished run, runs held back or without a new commit are not reported
+func (b *backend) sendRunFinishedEvent(tracker *runTracker) {
+	run := tracker.run
+	switch run.Status {
+	case runs.StatusSucceeded:
+		b.sendRunEvent(tracker, eventTypeRunSucceeded, nil)
+	case runs.StatusFailed:
+		b.sendRunEvent(tracker, eventTypeRunFailed, map[string]interface{}{
+			"error":       run.Error,
+			"error_class": retry.ErrorClass(errors.New(run.Error)),
+		})
+	case runs.StatusCancelled:
+		b.sendRunEvent(tracker, eventTypeRunFailed, map[string]interface{}{
+			"error":       run.Error,
+			"error_class": errorClassCancelled,
+		})
+	}
+}
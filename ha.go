@@ -0,0 +1,198 @@
+package gitops_terraform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/lease"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/vault_client"
+)
+
+const (
+	// runLeaseTTL is the time after which the lease of a node which stopped heartbeating is taken over
+	runLeaseTTL             = 5 * time.Minute
+	runLeaseHeartbeatPeriod = time.Minute
+	// runLeaseSettleTime is waited before the written lease is read back, see lease.Acquire
+	runLeaseSettleTime = 2 * time.Second
+)
+
+// newNodeID returns the lease owner id of this plugin instance: the host name with a random suffix
+func newNodeID() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", fmt.Errorf("unable to generate node id: %w", err)
+	}
+
+	return hostname + "/" + id, nil
+}
+
+// isReplicaNode returns true on performance standbys and DR secondaries, and on performance secondaries
+// for replicated mounts: terraform must run only on the active node of the cluster owning the mount
+func (b *backend) isReplicaNode() bool {
+	state := b.System().ReplicationState()
+	if state.HasState(consts.ReplicationPerformanceStandby | consts.ReplicationDRSecondary) {
+		return true
+	}
+	return state.HasState(consts.ReplicationPerformanceSecondary) && !b.System().LocalMount()
+}
+
+// withRunLease calls fn holding the run lease of the workspace, the lease is heartbeated until fn returns.
+// The context of fn is cancelled when the lease is lost: it expired or was taken over by another node.
+// Returns *lease.HeldError if another node or run holds the lease and *lease.LostError if fn failed after the lease was lost
+func (b *backend) withRunLease(ctx context.Context, storage logical.Storage, workspace, runID string, fn func(ctx context.Context) error) error {
+	// Lease must be released even if the run is cancelled
+	leaseCtx := context.WithoutCancel(ctx)
+
+	runLease, err := lease.Acquire(leaseCtx, storage, b.nodeID, runID, systemClock.Now(), runLeaseTTL, runLeaseSettleTime)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	check := b.watchRunLease(workspace)
+	defer b.unwatchRunLease(workspace)
+
+	stop := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+
+		ticker := time.NewTicker(runLeaseHeartbeatPeriod)
+		defer ticker.Stop()
+		for {
+			var err error
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err = lease.Heartbeat(leaseCtx, storage, runLease, systemClock.Now(), runLeaseTTL)
+			case <-check:
+				err = lease.Verify(leaseCtx, storage, runLease, systemClock.Now())
+			}
+
+			var lostErr *lease.LostError
+			switch {
+			case errors.As(err, &lostErr):
+				b.Logger().Warn("Run lease is lost, cancelling the run", "runID", runID, "workspace", workspace)
+				cancel(lostErr)
+				return
+			case err != nil:
+				// The lease is still valid until it expires, the next heartbeat tries again
+				b.Logger().Warn(fmt.Sprintf("Unable to heartbeat run lease: %v", err), "runID", runID)
+			}
+		}
+	}()
+
+	defer func() {
+		close(stop)
+		<-heartbeatDone
+		if err := lease.Release(leaseCtx, storage, runLease); err != nil {
+			b.Logger().Warn(fmt.Sprintf("Unable to release run lease: %v", err), "runID", runID)
+		}
+	}()
+
+	err = fn(runCtx)
+	var lostErr *lease.LostError
+	if err != nil && errors.As(context.Cause(runCtx), &lostErr) {
+		return fmt.Errorf("%w: %w", lostErr, err)
+	}
+	return err
+}
+
+// watchRunLease returns the channel notified when the run lease of the workspace is changed by another node
+func (b *backend) watchRunLease(workspace string) <-chan struct{} {
+	b.runLeaseChecksMutex.Lock()
+	defer b.runLeaseChecksMutex.Unlock()
+
+	check := make(chan struct{}, 1)
+	b.runLeaseChecks[workspace] = check
+	return check
+}
+
+func (b *backend) unwatchRunLease(workspace string) {
+	b.runLeaseChecksMutex.Lock()
+	defer b.runLeaseChecksMutex.Unlock()
+
+	delete(b.runLeaseChecks, workspace)
+}
+
+// checkRunLease makes the run of the workspace holding the lease verify it at once
+func (b *backend) checkRunLease(workspace string) {
+	b.runLeaseChecksMutex.Lock()
+	defer b.runLeaseChecksMutex.Unlock()
+
+	check, ok := b.runLeaseChecks[workspace]
+	if !ok {
+		return
+	}
+	select {
+	case check <- struct{}{}:
+	default:
+	}
+}
+
+// initialize finishes runs which were in progress when the plugin was stopped, nothing runs yet on this instance
+func (b *backend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
+	if b.isReplicaNode() {
+		return nil
+	}
+
+	b.migrateStorage(ctx, req.Storage)
+
+	finished, err := runs.FinishInterrupted(ctx, req.Storage, systemClock.Now())
+	if err != nil {
+		// History must not prevent the plugin from starting
+		b.Logger().Warn(fmt.Sprintf("Unable to finish interrupted runs: %v", err))
+		return nil
+	}
+	if finished > 0 {
+		b.Logger().Info("Interrupted runs are marked cancelled", "runs", finished)
+	}
+
+	return nil
+}
+
+// migrateStorage upgrades storage of the default and named workspaces, the runs migrate it on finish anyway
+func (b *backend) migrateStorage(ctx context.Context, storage logical.Storage) {
+	workspaces, err := listWorkspaces(ctx, storage)
+	if err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to list workspaces: %v", err))
+	}
+
+	for _, workspace := range append([]string{""}, workspaces...) {
+		if err := migrateLastAppliedCommit(ctx, workspaceStorage(storage, workspace)); err != nil {
+			b.Logger().Warn(fmt.Sprintf("Unable to migrate last applied commit: %v", err), "workspace", workspace)
+		}
+	}
+}
+
+// invalidate drops state cached in memory when its storage key is changed by another node.
+// Other configuration is read from storage by every run
+func (b *backend) invalidate(_ context.Context, key string) {
+	switch {
+	case key == vault_client.StorageKeyConfiguration:
+		b.Logger().Debug("Vault configuration changed, token TTL is looked up again")
+		b.updateVaultTokenTTL(nil)
+		b.resetTokenExpiringNotification()
+	case key == lease.StorageKeyLease:
+		b.checkRunLease("")
+	case strings.HasPrefix(key, storageKeyPrefixWorkspace) && strings.HasSuffix(key, "/"+lease.StorageKeyLease):
+		b.checkRunLease(strings.TrimSuffix(strings.TrimPrefix(key, storageKeyPrefixWorkspace), "/"+lease.StorageKeyLease))
+	}
+}
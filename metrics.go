@@ -0,0 +1,115 @@
+package gitops_terraform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/metrics"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/terraform"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+func metricsPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "metrics/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathMetricsRead,
+					Summary:  "Read metrics in Prometheus text format.",
+				},
+			},
+			HelpSynopsis:    metricsHelpSyn,
+			HelpDescription: metricsHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathMetricsRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	families := b.metrics.Families()
+
+	gauges, err := b.storageGauges(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to collect metrics: %s", err), nil
+	}
+	families = append(families, gauges...)
+
+	tokenTTL := metrics.Family{Name: "gitops_terraform_vault_token_ttl_seconds", Type: metrics.TypeGauge, Help: "Remaining TTL of the Vault token used by terraform."}
+	// Not looked up yet or the token never expires
+	if ttl := b.getVaultTokenTTL(); ttl != nil && ttl.TTL > 0 {
+		tokenTTL.Samples = append(tokenTTL.Samples, metrics.Sample{Value: time.Until(ttl.ExpireTime).Seconds()})
+	}
+	families = append(families, tokenTTL)
+
+	var body bytes.Buffer
+	if err := metrics.Write(&body, families); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{Data: map[string]interface{}{
+		logical.HTTPContentType: metrics.ContentType,
+		logical.HTTPRawBody:     body.Bytes(),
+		logical.HTTPStatusCode:  http.StatusOK,
+	}}, nil
+}
+
+// storageGauges returns gauges of every configured workspace read from storage
+func (b *backend) storageGauges(ctx context.Context, storage logical.Storage) ([]metrics.Family, error) {
+	lastApply := metrics.Family{Name: "gitops_terraform_last_successful_apply_timestamp_seconds", Type: metrics.TypeGauge, Help: "Unix time of the last successful apply."}
+	stateSize := metrics.Family{Name: "gitops_terraform_state_size_bytes", Type: metrics.TypeGauge, Help: "Size of the terraform state."}
+
+	workspaces, err := listWorkspaces(ctx, storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list workspaces: %w", err)
+	}
+
+	for _, workspace := range append([]string{""}, workspaces...) {
+		wsStorage := workspaceStorage(storage, workspace)
+
+		// Skip not configured workspace, e.g. the default one when only named workspaces are used
+		configEntry, err := wsStorage.Get(ctx, git_repository.StorageKeyConfiguration)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get git repository configuration: %w", err)
+		}
+		if configEntry == nil {
+			continue
+		}
+
+		labels := []metrics.Label{{Name: "workspace", Value: workspace}}
+
+		lastApplyTimestamp, err := util.GetInt64(ctx, wsStorage, storageKeyLastApplyTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get apply timestamp: %w", err)
+		}
+		if lastApplyTimestamp > 0 {
+			lastApply.Samples = append(lastApply.Samples, metrics.Sample{Labels: labels, Value: float64(lastApplyTimestamp)})
+		}
+
+		size, err := terraform.StateSize(ctx, wsStorage)
+		if err != nil {
+			return nil, err
+		}
+		stateSize.Samples = append(stateSize.Samples, metrics.Sample{Labels: labels, Value: float64(size)})
+	}
+
+	return []metrics.Family{lastApply, stateSize}, nil
+}
+
+const (
+	metricsHelpSyn = `
+Metrics of the gitops_terraform backend in Prometheus text format.
+`
+	metricsHelpDesc = `
+Counters of runs by status, time spent in phases and commits skipped for
+insufficient signatures are kept in memory and start from zero when the plugin
+is loaded. Last successful apply time and state size of every workspace are read
+from storage, the remaining TTL of the Vault token is reported once it is looked up.
+`
+)
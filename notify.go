@@ -0,0 +1,96 @@
+package gitops_terraform
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/notifications"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/retry"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+// notify sends the event to subscribed channels in background, the result is recorded per channel
+func (b *backend) notify(storage logical.Storage, event *notifications.Event) {
+	event.Time = systemClock.Now()
+
+	go func() {
+		ctx := context.Background()
+
+		channels, err := notifications.ListSubscribed(ctx, storage, event.Type)
+		if err != nil {
+			b.Logger().Warn(fmt.Sprintf("Unable to get notification channels: %v", err))
+			return
+		}
+
+		for _, channel := range channels {
+			delivery := notifications.Deliver(ctx, channel, event)
+			if !delivery.Delivered {
+				b.Logger().Warn("Unable to deliver notification", "channel", channel.Name, "event", event.Type, "attempts", delivery.Attempts, "err", delivery.Error)
+			}
+			if err := notifications.PutLastDelivery(ctx, storage, channel.Name, delivery); err != nil {
+				b.Logger().Warn(fmt.Sprintf("Unable to store notification delivery: %v", err))
+			}
+		}
+	}()
+}
+
+// notifyRun sends the event about the commit of the run
+func (b *backend) notifyRun(tracker *runTracker, eventType, message string) {
+	run := tracker.run
+
+	planSummary := run.PlanSummary
+	if planSummary == nil && run.DriftChanges != nil {
+		planSummary = runs.NewPlanSummary(run.DriftChanges)
+	}
+
+	b.notify(tracker.storage, &notifications.Event{
+		Type:         eventType,
+		Workspace:    run.Workspace,
+		RunID:        run.ID,
+		CommitHash:   run.CommitHash,
+		CommitAuthor: run.CommitAuthor,
+		Signers:      run.Signers,
+		PlanSummary:  planSummary,
+		Message:      message,
+	})
+}
+
+// notifyRunFailed sends quarantined event if the commit of the failed run got quarantined, failed event otherwise
+func (b *backend) notifyRunFailed(tracker *runTracker) {
+	eventType := notifications.EventFailed
+
+	failure, err := retry.GetFailure(tracker.ctx, tracker.storage)
+	if err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to get commit failure: %v", err))
+	}
+	if failure != nil && failure.Quarantined && failure.CommitHash == tracker.run.CommitHash {
+		eventType = notifications.EventQuarantined
+	}
+
+	b.notifyRun(tracker, eventType, tracker.run.Error)
+}
+
+// notifyTokenExpiring sends token_expiring event once until the token TTL is long enough again
+func (b *backend) notifyTokenExpiring(storage logical.Storage, message string) {
+	b.vaultTokenExpireMutex.Lock()
+	notified := b.vaultTokenExpiringNotified
+	b.vaultTokenExpiringNotified = true
+	b.vaultTokenExpireMutex.Unlock()
+
+	if notified {
+		return
+	}
+
+	b.notify(storage, &notifications.Event{
+		Type:    notifications.EventTokenExpiring,
+		Message: message,
+	})
+}
+
+func (b *backend) resetTokenExpiringNotification() {
+	b.vaultTokenExpireMutex.Lock()
+	defer b.vaultTokenExpireMutex.Unlock()
+	b.vaultTokenExpiringNotified = false
+}
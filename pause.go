@@ -0,0 +1,135 @@
+package gitops_terraform
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	fieldNamePauseReason = "reason"
+
+	storageKeyPauseState    = "pause_state"
+	storageKeyPendingCommit = "pending_commit"
+)
+
+// PendingCommit is a found signed commit which is not applied yet
+type PendingCommit struct {
+	CommitHash string    `json:"commit_hash"`
+	CommitDate time.Time `json:"commit_date"`
+	// DeferredUntil is set when the commit waits for the maintenance window
+	DeferredUntil time.Time `json:"deferred_until"`
+}
+
+// PauseState is stored while automation is paused
+type PauseState struct {
+	Reason      string    `json:"reason"`
+	RequestedBy string    `json:"requested_by"`
+	PausedAt    time.Time `json:"paused_at"`
+}
+
+func pausePaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "^pause/?$",
+			Fields: map[string]*framework.FieldSchema{
+				fieldNamePauseReason: {
+					Type:        framework.TypeString,
+					Description: "Reason of the pause. Required.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathPauseWrite,
+					Summary:  "Stop applying commits.",
+				},
+			},
+			HelpSynopsis:    pauseHelpSyn,
+			HelpDescription: pauseHelpDesc,
+		},
+		{
+			Pattern: "^resume/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathResumeWrite,
+					Summary:  "Resume applying commits.",
+				},
+			},
+			HelpSynopsis:    pauseHelpSyn,
+			HelpDescription: pauseHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathPauseWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	reason := data.Get(fieldNamePauseReason).(string)
+	if reason == "" {
+		return logical.ErrorResponse("%q field value should not be empty", fieldNamePauseReason), nil
+	}
+
+	state := &PauseState{
+		Reason:      reason,
+		RequestedBy: req.DisplayName,
+		PausedAt:    systemClock.Now(),
+	}
+
+	if err := util.PutJSON(ctx, req.Storage, storageKeyPauseState, state); err != nil {
+		return nil, fmt.Errorf("unable to store pause state: %w", err)
+	}
+
+	b.Logger().Info("Automation paused", "reason", state.Reason, "requestedBy", state.RequestedBy)
+
+	return nil, nil
+}
+
+func (b *backend) pathResumeWrite(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	if err := req.Storage.Delete(ctx, storageKeyPauseState); err != nil {
+		return nil, fmt.Errorf("unable to delete pause state: %w", err)
+	}
+
+	b.Logger().Info("Automation resumed", "requestedBy", req.DisplayName)
+
+	return nil, nil
+}
+
+// getPauseState returns nil if automation is not paused
+func getPauseState(ctx context.Context, storage logical.Storage) (*PauseState, error) {
+	var state *PauseState
+	if err := util.GetJSON(ctx, storage, storageKeyPauseState, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func storePendingCommit(ctx context.Context, storage logical.Storage, pendingCommit *PendingCommit) error {
+	return util.PutJSON(ctx, storage, storageKeyPendingCommit, pendingCommit)
+}
+
+// getPendingCommit returns nil if there is no pending commit
+func getPendingCommit(ctx context.Context, storage logical.Storage) (*PendingCommit, error) {
+	var pendingCommit *PendingCommit
+	if err := util.GetJSON(ctx, storage, storageKeyPendingCommit, &pendingCommit); err != nil {
+		return nil, err
+	}
+	return pendingCommit, nil
+}
+
+func deletePendingCommit(ctx context.Context, storage logical.Storage) error {
+	return storage.Delete(ctx, storageKeyPendingCommit)
+}
+
+const (
+	pauseHelpSyn = `
+Pause and resume applying commits.
+`
+	pauseHelpDesc = `
+While paused the plugin keeps polling the git repository and reports a new
+signed commit as pending_commit by the status endpoint, but never applies it.
+The configuration is preserved. Resume to continue applying commits.
+`
+)
@@ -2,6 +2,7 @@
 // On each iteration:
 // 1. Search from HEAD backwards to last_finished_commit (or initial_last_successful_commit if not set)
 // 2. Find the first commit that has the required number of verified signatures
+//    (with apply_mode=sequential every such commit of the first-parent history, the oldest first)
 // 3. Call processCommit for that commit
 // 4. If processCommit succeeds, save the commit as last_finished_commit
 // 5. Next search will be from HEAD to the new last_finished_commit
@@ -10,13 +11,24 @@ package gitops_terraform
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync/atomic"
 	"time"
 
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/directives"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/lease"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/notifications"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/plans"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/retry"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/schedule"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/terraform"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
 )
 
@@ -31,15 +43,58 @@ type LastFinishedCommit struct {
 	CommitDate time.Time `json:"commit_date"`
 }
 
+// skipReasonNoRelevantChanges is the reason of a commit finished without running terraform by the change filter
+const skipReasonNoRelevantChanges = "no relevant changes"
+
 const (
 	storageKeyLastFinishedCommit = "last_processed_commit"
+	storageKeyLastAppliedCommit  = "last_applied_commit"
 	lastPeriodicRunTimestampKey  = "last_periodic_run_timestamp"
 	storageKeyProcessStatus      = "process_status"
+	storageKeyLastRunID          = "last_run_id"
+	storageKeyLastApplySummary   = "last_apply_summary"
+	storageKeyHeadCommit         = "head_commit"
+	storageKeyLastApplyTimestamp = "last_apply_timestamp"
+	storageKeyRejectedCommits    = "rejected_commits"
+)
+
+// Sources which can trigger processGit
+const (
+	triggerPeriodic = "periodic"
+	triggerManual   = "manual"
+	triggerWebhook  = "webhook"
+	triggerRollback = "rollback"
+	triggerApproval = "approval"
 )
 
+// HeadCommit is the HEAD of the branch seen by the last run
+type HeadCommit struct {
+	CommitHash string    `json:"commit_hash"`
+	CommitDate time.Time `json:"commit_date"`
+	Signed     bool      `json:"signed"`
+	SeenAt     time.Time `json:"seen_at"`
+}
+
+// processGitOptions describes a single processGit run
+type processGitOptions struct {
+	RunID   string
+	Trigger string
+	// Workspace is empty for the default workspace of the mount
+	Workspace string
+	// Force re-applies last finished commit if no newer signed commit found
+	Force bool
+	// Rollback, if set, applies the verified historical commit instead of searching for a new one
+	Rollback *RollbackOverride
+}
+
 func (b *backend) PeriodicTask(storage logical.Storage) error {
 	ctx := context.Background()
 
+	if b.isReplicaNode() {
+		b.Logger().Trace("Replica node, skipping periodic task")
+		return nil
+	}
+
 	// Check and update vault token expire time if needed (using vault_client functions)
 	// Run asynchronously to avoid blocking PeriodicTask
 	go func() {
@@ -48,52 +103,105 @@ func (b *backend) PeriodicTask(storage logical.Storage) error {
 		}
 	}()
 
+	// Enforce run history retention
+	if _, err := runs.Prune(ctx, storage, systemClock.Now()); err != nil {
+		b.Logger().Warn(fmt.Sprintf("Failed to prune run history: %v", err))
+	}
+
+	// The default workspace of the mount, its failure does not stop named workspaces
+	if err := b.startPeriodicRun(ctx, storage, ""); err != nil {
+		b.Logger().Warn(fmt.Sprintf("Failed to start run of the default workspace: %v", err))
+	}
+
+	workspaces, err := listWorkspaces(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to list workspaces: %w", err)
+	}
+	for _, workspace := range workspaces {
+		if err := b.startPeriodicRun(ctx, storage, workspace); err != nil {
+			b.Logger().Warn(fmt.Sprintf("Failed to start run of workspace %q: %v", workspace, err))
+		}
+	}
+
+	return nil
+}
+
+// startPeriodicRun starts processGit of the workspace unless its run is already in progress
+func (b *backend) startPeriodicRun(ctx context.Context, storage logical.Storage, workspace string) error {
+	storage = workspaceStorage(storage, workspace)
+
+	// Skip not configured workspace, e.g. the default one when only named workspaces are used
+	configEntry, err := storage.Get(ctx, git_repository.StorageKeyConfiguration)
+	if err != nil {
+		return fmt.Errorf("unable to get git repository configuration: %w", err)
+	}
+	if configEntry == nil {
+		return nil
+	}
+
 	// Get last finished commit
 	var lastFinishedCommit *LastFinishedCommit
-	err := util.GetJSON(ctx, storage, storageKeyLastFinishedCommit, &lastFinishedCommit)
-	if err != nil {
+	if err := util.GetJSON(ctx, storage, storageKeyLastFinishedCommit, &lastFinishedCommit); err != nil {
 		return fmt.Errorf("unable to get last finished commit: %w", err)
 	}
 
-	// Check if processGit is already running using CAS guard
-	if !atomic.CompareAndSwapUint32(b.processGitCASGuard, 0, 1) {
-		b.Logger().Debug("GitOps task already in progress, skipping this iteration")
-		return nil
+	runID, err := uuid.GenerateUUID()
+	if err != nil {
+		return fmt.Errorf("unable to generate run id: %w", err)
 	}
 
 	// Launch processGit in a goroutine to avoid blocking PeriodicTask
-	go b.processGitInternal(storage, lastFinishedCommit)
+	if !b.startProcessGit(storage, lastFinishedCommit, processGitOptions{RunID: runID, Trigger: triggerPeriodic, Workspace: workspace}) {
+		b.Logger().Debug("GitOps task already in progress, skipping this iteration", "workspace", workspace)
+	}
 
 	return nil
 }
 
+// startProcessGit launches processGit in a goroutine if it is not already running in the workspace
+// Storage must be the storage of the workspace. Returns false if another run holds the CAS guard
+func (b *backend) startProcessGit(storage logical.Storage, lastFinishedCommit *LastFinishedCommit, opts processGitOptions) bool {
+	// Check if processGit is already running using CAS guard
+	if !atomic.CompareAndSwapUint32(b.processGitCASGuard(opts.Workspace), 0, 1) {
+		return false
+	}
+
+	go b.processGitInternal(storage, lastFinishedCommit, opts)
+
+	return true
+}
+
 // processGitInternal is the internal function that runs in a goroutine
 // It ensures the CAS guard is reset when the function completes (successfully or with error)
-func (b *backend) processGitInternal(storage logical.Storage, lastFinishedCommit *LastFinishedCommit) {
-	defer atomic.StoreUint32(b.processGitCASGuard, 0)
+func (b *backend) processGitInternal(storage logical.Storage, lastFinishedCommit *LastFinishedCommit, opts processGitOptions) {
+	defer atomic.StoreUint32(b.processGitCASGuard(opts.Workspace), 0)
 
-	// Don't cancel when the original client request goes away
-	ctx := context.Background()
+	// Don't cancel when the original client request goes away, only by runs/current/cancel or plugin unload
+	ctx, finish := b.beginRun(opts.Workspace, opts.RunID)
+	defer finish()
 
-	if err := b.processGit(ctx, storage, lastFinishedCommit); err != nil {
-		b.Logger().Warn(fmt.Sprintf("Cant process gitops task: %v", err))
+	if err := b.processGit(ctx, storage, lastFinishedCommit, opts); err != nil {
+		b.Logger().Warn(fmt.Sprintf("Cant process gitops task: %v", err), "workspace", opts.Workspace)
 	}
 }
 
-func (b *backend) processGit(ctx context.Context, storage logical.Storage, lastFinishedCommit *LastFinishedCommit) error {
+func (b *backend) processGit(ctx context.Context, storage logical.Storage, lastFinishedCommit *LastFinishedCommit, opts processGitOptions) (err error) {
 	config, err := git_repository.GetConfig(ctx, storage, b.Logger())
 	if err != nil {
 		return err
 	}
 
-	gitCheckintervalExceeded, err := checkExceedingInterval(ctx, storage, config.GitPollPeriod)
-	if err != nil {
-		return err
-	}
+	// Manual triggers bypass git_poll_period
+	if opts.Trigger == triggerPeriodic {
+		gitCheckintervalExceeded, err := checkExceedingInterval(ctx, storage, config.GitPollPeriod)
+		if err != nil {
+			return err
+		}
 
-	if !gitCheckintervalExceeded {
-		b.Logger().Debug("git poll interval not exceeded, finish periodic task")
-		return nil
+		if !gitCheckintervalExceeded {
+			b.Logger().Debug("git poll interval not exceeded, finish periodic task")
+			return nil
+		}
 	}
 
 	newTimeStamp := systemClock.Now()
@@ -101,6 +209,22 @@ func (b *backend) processGit(ctx context.Context, storage logical.Storage, lastF
 		return err
 	}
 
+	if err := util.PutString(ctx, storage, storageKeyLastRunID, opts.RunID); err != nil {
+		return fmt.Errorf("unable to store run id: %w", err)
+	}
+
+	b.Logger().Debug("Starting gitops run", "runID", opts.RunID, "workspace", opts.Workspace, "trigger", opts.Trigger, "force", opts.Force)
+
+	tracker := b.newRunTracker(ctx, storage, opts)
+	b.sendRunEvent(tracker, eventTypeRunStarted, nil)
+	defer func() {
+		tracker.Finish(err)
+		b.sendRunFinishedEvent(tracker)
+		if tracker.run.Status == runs.StatusFailed {
+			b.notifyRunFailed(tracker)
+		}
+	}()
+
 	// Convert LastFinishedCommit to LastFinishedCommitInfo for git_repository
 	var lastFinishedCommitInfo *git_repository.CommitInfo
 	if lastFinishedCommit != nil {
@@ -110,51 +234,462 @@ func (b *backend) processGit(ctx context.Context, storage logical.Storage, lastF
 		}
 	}
 
-	// Find first signed commit from HEAD backwards to lastFinishedCommit
-	commitInfo, err := git_repository.GitService(ctx, storage, b.Logger()).FindFirstSignedCommitFromHead(lastFinishedCommitInfo)
+	// Drift check, forced re-apply and change detection use the commit terraform state corresponds to,
+	// a commit finished by Gitops-Mode: plan-only is not applied
+	lastAppliedCommit, err := getLastAppliedCommit(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to get last applied commit: %w", err)
+	}
+	var lastAppliedCommitInfo *git_repository.CommitInfo
+	if lastAppliedCommit != nil {
+		lastAppliedCommitInfo = &git_repository.CommitInfo{
+			CommitHash: lastAppliedCommit.CommitHash,
+			CommitDate: lastAppliedCommit.CommitDate,
+		}
+	}
+
+	// After a rollback only commits newer than the branch HEAD at the time of the rollback are searched
+	searchBoundary := lastFinishedCommitInfo
+	rollbackOverride, err := getRollbackOverride(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to get rollback override: %w", err)
+	}
+	if rollbackOverride != nil {
+		searchBoundary = &git_repository.CommitInfo{
+			CommitHash: rollbackOverride.BranchHeadHash,
+			CommitDate: rollbackOverride.BranchHeadDate,
+		}
+	}
+
+	tfConfig, err := terraform.GetConfig(ctx, storage)
 	if err != nil {
-		return fmt.Errorf("finding signed commit: %w", err)
+		return fmt.Errorf("unable to get terraform configuration: %w", err)
 	}
 
-	if commitInfo == nil {
+	// Found commits are compared to the applied one, not to the rollback boundary
+	var changeBase string
+	if lastAppliedCommit != nil {
+		changeBase = lastAppliedCommit.CommitHash
+	}
+
+	// Every search walks the same unsigned commits again, each of them is reported once
+	rejected := b.loadRejectedCommits(ctx, storage)
+	defer b.storeRejectedCommits(ctx, storage, rejected)
+
+	// Find signed commits from HEAD backwards to the boundary
+	gitService := git_repository.GitService(ctx, storage, b.Logger()).
+		WithPhaseCallback(tracker.SetPhase).
+		WithHeadCallback(func(head *git_repository.HeadCommit) {
+			b.recordHeadCommit(ctx, storage, opts.Workspace, head)
+		}).
+		WithRejectCallback(func(commitHash string) {
+			if !rejected.add(commitHash) {
+				return
+			}
+			b.metrics.CommitRejected(opts.Workspace)
+			b.sendEvent(ctx, eventTypeCommitRejected, map[string]interface{}{
+				"run_id":      opts.RunID,
+				"workspace":   opts.Workspace,
+				"commit_hash": commitHash,
+				"reason":      "insufficient verified signatures",
+			})
+		}).
+		WithChangeFilter(changeBase, tfConfig.HasRelevantChanges)
+	// A rollback waiting for approval is processed instead of new commits
+	if opts.Rollback == nil {
+		opts.Rollback, err = pendingRollback(ctx, storage)
+		if err != nil {
+			return fmt.Errorf("unable to get pending rollback: %w", err)
+		}
+	}
+
+	var commits []*git_repository.CommitInfo
+	switch {
+	case opts.Rollback != nil:
+		commits = append(commits, &git_repository.CommitInfo{
+			CommitHash: opts.Rollback.CommitHash,
+			CommitDate: opts.Rollback.CommitDate,
+		})
+	case config.ApplyMode == git_repository.ApplyModeSequential:
+		commits, err = gitService.FindSignedCommitsSequence(searchBoundary)
+		if err != nil {
+			return fmt.Errorf("finding signed commits: %w", err)
+		}
+		rejected.complete = true
+	default:
+		commitInfo, err := gitService.FindFirstSignedCommitFromHead(searchBoundary)
+		if err != nil {
+			return fmt.Errorf("finding signed commit: %w", err)
+		}
+		rejected.complete = true
+		if commitInfo != nil {
+			commits = append(commits, commitInfo)
+		}
+	}
+
+	// Forced run re-applies the last finished commit when nothing newer is signed
+	if len(commits) == 0 && opts.Force && lastAppliedCommit != nil {
+		b.Logger().Info("No new signed commit found, forcing re-apply of last applied commit", "commitHash", lastAppliedCommit.CommitHash)
+		commits = append(commits, lastAppliedCommitInfo)
+	}
+
+	if len(commits) == 0 {
 		b.Logger().Debug("No signed commit found: finish periodic task")
+		if err := deletePendingCommit(ctx, storage); err != nil {
+			return fmt.Errorf("unable to delete pending commit: %w", err)
+		}
 		// TODO: do not store status when already same status
 		if err := storeProcessStatusCommit(ctx, storage, "No new signed commit found"); err != nil {
 			return fmt.Errorf("unable to store process status commit: %w", err)
 		}
-		return nil
+		return b.checkDrift(ctx, storage, tracker, opts, lastAppliedCommitInfo)
+	}
+
+	// Commits are applied in order, the run stops at the first commit which is not applied
+	for i, commitInfo := range commits {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		applied, err := b.applyCommit(ctx, storage, tracker, opts, commitInfo)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		// A quarantined commit does not block newer commits of the sequence: it is finished without applying
+		// and is not applied later, a newer commit includes its fix
+		failure, err := retry.GetFailure(ctx, storage)
+		if err != nil {
+			return fmt.Errorf("unable to get commit failure: %w", err)
+		}
+		if !failure.IsPassedOver(commitInfo.CommitHash, i < len(commits)-1) {
+			return nil
+		}
+		b.Logger().Warn("Quarantined commit is passed over for newer commits", "commitHash", commitInfo.CommitHash)
+		if err := finishCommit(ctx, storage, opts, commitInfo, fmt.Sprintf("Commit %q is quarantined, passed over for newer commits", commitInfo.CommitHash)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyCommit processes the commit unless it is held back by retry policy, pause or schedule
+// Returns false if the commit was held back
+func (b *backend) applyCommit(ctx context.Context, storage logical.Storage, tracker *runTracker, opts processGitOptions, commitInfo *git_repository.CommitInfo) (bool, error) {
+	tracker.SetCommit(commitInfo)
+
+	// A failed commit is retried with backoff and skipped while quarantined
+	failure, err := retry.GetFailure(ctx, storage)
+	if err != nil {
+		return false, fmt.Errorf("unable to get commit failure: %w", err)
+	}
+	if failure != nil && failure.CommitHash == commitInfo.CommitHash {
+		if failure.Quarantined {
+			b.Logger().Debug("Commit is quarantined, skipping", "commitHash", commitInfo.CommitHash)
+			tracker.SetStatus(runs.StatusQuarantined)
+			if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Commit %q is quarantined after %d failed attempts: %s", commitInfo.CommitHash, failure.Attempts, failure.LastError)); err != nil {
+				return false, fmt.Errorf("unable to store process status commit: %w", err)
+			}
+			return false, nil
+		}
+
+		// Manual triggers do not wait for the backoff
+		if opts.Trigger != triggerManual && opts.Trigger != triggerRollback && opts.Trigger != triggerApproval && systemClock.Now().Before(failure.NextAttemptAt) {
+			b.Logger().Debug("Commit is in backoff, skipping", "commitHash", commitInfo.CommitHash, "nextAttemptAt", failure.NextAttemptAt)
+			tracker.SetStatus(runs.StatusBackoff)
+			if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Commit %q failed %d times, next attempt at %s", commitInfo.CommitHash, failure.Attempts, failure.NextAttemptAt.Format(time.RFC3339))); err != nil {
+				return false, fmt.Errorf("unable to store process status commit: %w", err)
+			}
+			return false, nil
+		}
+	}
+
+	// While paused the commit is only reported as pending
+	pauseState, err := getPauseState(ctx, storage)
+	if err != nil {
+		return false, fmt.Errorf("unable to get pause state: %w", err)
+	}
+	if pauseState != nil {
+		b.Logger().Info("Automation is paused, commit is pending", "commitHash", commitInfo.CommitHash, "reason", pauseState.Reason)
+		tracker.SetStatus(runs.StatusPaused)
+		if err := storePendingCommit(ctx, storage, &PendingCommit{CommitHash: commitInfo.CommitHash, CommitDate: commitInfo.CommitDate}); err != nil {
+			return false, fmt.Errorf("unable to store pending commit: %w", err)
+		}
+		if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Paused: commit %q is pending", commitInfo.CommitHash)); err != nil {
+			return false, fmt.Errorf("unable to store process status commit: %w", err)
+		}
+		return false, nil
+	}
+
+	// Outside maintenance windows and during freezes the commit waits for the next allowed time
+	deferredUntil, err := nextAllowedApplyTime(ctx, storage)
+	if err != nil {
+		return false, fmt.Errorf("unable to check schedule: %w", err)
+	}
+	if !deferredUntil.IsZero() {
+		b.Logger().Info("Applying is not allowed by schedule, commit is deferred", "commitHash", commitInfo.CommitHash, "deferredUntil", deferredUntil)
+		tracker.SetStatus(runs.StatusDeferred)
+		if err := storePendingCommit(ctx, storage, &PendingCommit{CommitHash: commitInfo.CommitHash, CommitDate: commitInfo.CommitDate, DeferredUntil: deferredUntil}); err != nil {
+			return false, fmt.Errorf("unable to store pending commit: %w", err)
+		}
+		if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Commit %q deferred until %s", commitInfo.CommitHash, deferredUntil.Format(time.RFC3339))); err != nil {
+			return false, fmt.Errorf("unable to store process status commit: %w", err)
+		}
+		return false, nil
+	}
+	if err := deletePendingCommit(ctx, storage); err != nil {
+		return false, fmt.Errorf("unable to delete pending commit: %w", err)
+	}
+
+	// A commit which changes nothing under terraform_path and watch_paths is finished without running terraform,
+	// unless its directives ask for something
+	if commitInfo.NoRelevantChanges && len(commitInfo.Directives) == 0 {
+		b.Logger().Info("Commit has no relevant changes, skipping", "commitHash", commitInfo.CommitHash)
+		tracker.SetSkipped(skipReasonNoRelevantChanges)
+		if err := finishCommit(ctx, storage, opts, commitInfo, fmt.Sprintf("Commit %q is skipped: %s", commitInfo.CommitHash, skipReasonNoRelevantChanges)); err != nil {
+			return false, err
+		}
+		return true, nil
 	}
 
 	b.Logger().Info("Found signed commit to process", "commitHash", commitInfo.CommitHash, "commitDate", commitInfo.CommitDate)
 
 	storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Processing commit %q", commitInfo.CommitHash))
 
-	err = b.processCommit(ctx, storage, commitInfo.CommitHash)
+	// Only one node of the cluster may run terraform against the state of the workspace
+	var applied bool
+	err = b.withRunLease(ctx, storage, opts.Workspace, opts.RunID, func(ctx context.Context) error {
+		applied, err = b.applyOrPlanCommit(ctx, storage, tracker, opts, commitInfo)
+		return err
+	})
+	var heldErr *lease.HeldError
+	if errors.As(err, &heldErr) {
+		b.Logger().Info("Run lease is held by another node, commit is skipped", "commitHash", commitInfo.CommitHash, "owner", heldErr.Lease.Owner)
+		tracker.SetStatus(runs.StatusLeaseHeld)
+		if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Commit %q is not processed: %s", commitInfo.CommitHash, heldErr.Error())); err != nil {
+			return false, fmt.Errorf("unable to store process status commit: %w", err)
+		}
+		return false, nil
+	}
+	var lostErr *lease.LostError
+	if errors.As(err, &lostErr) {
+		// Another node took over the workspace, it processes the commit
+		b.Logger().Warn("Run lease is lost, commit is not processed", "commitHash", commitInfo.CommitHash, "error", err)
+		tracker.SetStatus(runs.StatusLeaseHeld)
+		if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Commit %q is not processed: %s", commitInfo.CommitHash, lostErr.Error())); err != nil {
+			return false, fmt.Errorf("unable to store process status commit: %w", err)
+		}
+		return false, nil
+	}
+	var guardErr *terraform.BlastRadiusError
+	if errors.As(err, &guardErr) {
+		// Not a failure of the commit: it is applied when it gets enough signatures
+		b.Logger().Warn("Commit is blocked by blast-radius guard", "commitHash", commitInfo.CommitHash, "violations", guardErr.Violations)
+		tracker.SetStatus(runs.StatusBlocked)
+		if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Commit %q %s", commitInfo.CommitHash, guardErr.Error())); err != nil {
+			return false, fmt.Errorf("unable to store process status commit: %w", err)
+		}
+		return false, nil
+	}
+	if err != nil && ctx.Err() != nil {
+		// Cancelled commit is not a failure of the commit, the next run processes it again
+		storeProcessStatusCommit(context.WithoutCancel(ctx), storage, fmt.Sprintf("Cancelled processing commit %q", commitInfo.CommitHash))
+		return false, fmt.Errorf("processing commit %q: %w: %w", commitInfo.CommitHash, ctx.Err(), err)
+	}
 	if err != nil {
-		storeProcessStatusCommit(ctx, storage, fmt.Sprintf("FAILED processing commit %q: %s", commitInfo.CommitHash, err.Error()))
-		return fmt.Errorf("processing commit %q: %w", commitInfo.CommitHash, err)
+		status := fmt.Sprintf("FAILED processing commit %q: %s", commitInfo.CommitHash, err.Error())
+		failure, recordErr := retry.RecordFailure(ctx, storage, commitInfo.CommitHash, err, systemClock.Now())
+		switch {
+		case recordErr != nil:
+			b.Logger().Warn(fmt.Sprintf("Unable to record commit failure: %v", recordErr))
+		case failure.Quarantined:
+			status += "; commit is quarantined"
+		default:
+			status += fmt.Sprintf("; next attempt at %s", failure.NextAttemptAt.Format(time.RFC3339))
+		}
+		storeProcessStatusCommit(ctx, storage, status)
+		return false, fmt.Errorf("processing commit %q: %w", commitInfo.CommitHash, err)
 	}
 
-	if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Successfully processed commit %q", commitInfo.CommitHash)); err != nil {
-		return fmt.Errorf("unable to store process status commit: %w", err)
+	if err := retry.ClearFailure(ctx, storage); err != nil {
+		return false, fmt.Errorf("unable to clear commit failure: %w", err)
 	}
 
-	// Convert CommitInfo to LastFinishedCommit and save
-	lastFinishedCommitToStore := &LastFinishedCommit{
-		CommitHash: commitInfo.CommitHash,
-		CommitDate: commitInfo.CommitDate,
+	if !applied {
+		// Plan is waiting for approval
+		return false, nil
 	}
 
+	status := fmt.Sprintf("Successfully processed commit %q", commitInfo.CommitHash)
+	switch tracker.run.Status {
+	case runs.StatusSkipped:
+		status = fmt.Sprintf("Commit %q is skipped: %s", commitInfo.CommitHash, tracker.run.SkipReason)
+	case runs.StatusPlanned:
+		status = fmt.Sprintf("Commit %q is planned only by %s directive: %s", commitInfo.CommitHash, directives.KeyMode, tracker.run.PlanSummary.String())
+	}
 	// Save last finished commit only if processCommit succeeded
-	if err := storeLastFinishedCommit(ctx, storage, lastFinishedCommitToStore); err != nil {
-		return fmt.Errorf("unable to save last finished commit: %w", err)
+	if err := finishCommit(ctx, storage, opts, commitInfo, status); err != nil {
+		return false, err
+	}
+	// Skipped and planned commits are finished without applying, the state still corresponds to the last applied commit
+	if tracker.run.Status == runs.StatusRunning {
+		if err := storeLastAppliedCommit(ctx, storage, &LastFinishedCommit{CommitHash: commitInfo.CommitHash, CommitDate: commitInfo.CommitDate}); err != nil {
+			return false, fmt.Errorf("unable to save last applied commit: %w", err)
+		}
+	}
+
+	b.Logger().Info("Successfully processed commit", "commitHash", commitInfo.CommitHash, "commitDate", commitInfo.CommitDate, "status", tracker.run.Status)
+	if tracker.run.Status == runs.StatusRunning {
+		b.notifyRun(tracker, notifications.EventSucceeded, fmt.Sprintf("Commit %q is applied", commitInfo.CommitHash))
+	}
+
+	return true, nil
+}
+
+// finishCommit stores the status and the commit as the last finished one, the next search stops at it
+func finishCommit(ctx context.Context, storage logical.Storage, opts processGitOptions, commitInfo *git_repository.CommitInfo, status string) error {
+	if err := storeProcessStatusCommit(ctx, storage, status); err != nil {
+		return fmt.Errorf("unable to store process status commit: %w", err)
 	}
 
-	b.Logger().Info("Successfully processed commit", "commitHash", commitInfo.CommitHash, "commitDate", commitInfo.CommitDate)
+	if err := migrateLastAppliedCommit(ctx, storage); err != nil {
+		return fmt.Errorf("unable to migrate last applied commit: %w", err)
+	}
+
+	// Re-apply of the last applied commit by forced sync or drift remediation does not move
+	// the last finished commit back behind commits which were only planned
+	lastAppliedCommit, err := getLastAppliedCommit(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to get last applied commit: %w", err)
+	}
+	if lastAppliedCommit == nil || lastAppliedCommit.CommitHash != commitInfo.CommitHash {
+		// Convert CommitInfo to LastFinishedCommit and save
+		lastFinishedCommitToStore := &LastFinishedCommit{
+			CommitHash: commitInfo.CommitHash,
+			CommitDate: commitInfo.CommitDate,
+		}
+		if err := storeLastFinishedCommit(ctx, storage, lastFinishedCommitToStore); err != nil {
+			return fmt.Errorf("unable to save last finished commit: %w", err)
+		}
+	}
+
+	if err := updateRollbackOverride(ctx, storage, opts.Rollback, commitInfo); err != nil {
+		return fmt.Errorf("unable to update rollback override: %w", err)
+	}
 
 	return nil
 }
 
+// applyOrPlanCommit applies the commit, or with require_approval applies only the approved plan of the commit
+// and otherwise stores the plan for approval. Returns false if the commit is waiting for approval
+// Directives of the commit message may skip the commit or only plan it, the commit is processed then
+func (b *backend) applyOrPlanCommit(ctx context.Context, storage logical.Storage, tracker *runTracker, opts processGitOptions, commitInfo *git_repository.CommitInfo) (bool, error) {
+	options, err := commitDirectives(ctx, storage, commitInfo)
+	if err != nil {
+		return false, err
+	}
+	tracker.SetDirectives(options)
+
+	switch {
+	case options.Skip:
+		b.Logger().Info("Commit is skipped by directive", "commitHash", commitInfo.CommitHash)
+		tracker.SetSkipped(directives.KeySkip + " directive")
+		return true, nil
+	case options.PlanOnly:
+		changes, err := b.planCommit(ctx, storage, commitInfo.CommitHash, tracker)
+		if err != nil {
+			return false, err
+		}
+		// Summary of an empty plan is recorded too, the status reports it
+		if changes == nil {
+			changes = []runs.ResourceChange{}
+		}
+		tracker.SetPlanSummary(changes)
+		tracker.SetStatus(runs.StatusPlanned)
+		return true, nil
+	}
+
+	tfConfig, err := terraform.GetConfig(ctx, storage)
+	if err != nil {
+		return false, err
+	}
+
+	var changes []runs.ResourceChange
+
+	if !tfConfig.RequireApproval {
+		changes, err = b.processCommit(ctx, storage, commitInfo.CommitHash, tracker)
+		tracker.SetPlanSummary(changes)
+	} else {
+		plan, err := b.activePlan(ctx, storage, commitInfo, tracker)
+		if err != nil {
+			return false, err
+		}
+		changes = plan.Changes
+		tracker.SetPlanSummary(changes)
+
+		if plan.Status != plans.StatusApproved {
+			b.Logger().Debug("Plan is waiting for approval", "commitHash", commitInfo.CommitHash)
+			// The rollback is kept until the plan is approved, runs do not search for new commits meanwhile
+			if opts.Rollback != nil {
+				if err := util.PutJSON(ctx, storage, storageKeyPendingRollback, opts.Rollback); err != nil {
+					return false, fmt.Errorf("unable to store pending rollback: %w", err)
+				}
+			}
+			tracker.SetStatus(runs.StatusAwaitingApproval)
+			if err := storeProcessStatusCommit(ctx, storage, fmt.Sprintf("Commit %q is planned, waiting for approval", commitInfo.CommitHash)); err != nil {
+				return false, fmt.Errorf("unable to store process status commit: %w", err)
+			}
+			return false, nil
+		}
+
+		err = b.applyPlan(ctx, storage, plan, tracker)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := util.PutJSON(ctx, storage, storageKeyLastApplySummary, runs.NewPlanSummary(changes)); err != nil {
+		return false, fmt.Errorf("unable to store apply summary: %w", err)
+	}
+	if err := util.PutInt64(ctx, storage, storageKeyLastApplyTimestamp, systemClock.Now().Unix()); err != nil {
+		return false, fmt.Errorf("unable to store apply timestamp: %w", err)
+	}
+
+	return true, nil
+}
+
+// commitDirectives returns processing options of the commit, directives must be allowed by configuration
+func commitDirectives(ctx context.Context, storage logical.Storage, commitInfo *git_repository.CommitInfo) (*directives.Options, error) {
+	config, err := directives.GetConfig(ctx, storage)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get commit directives configuration: %w", err)
+	}
+
+	return directives.Resolve(commitInfo.Directives, config.AllowedDirectives)
+}
+
+// nextAllowedApplyTime returns zero time if applying is allowed by the schedule now
+func nextAllowedApplyTime(ctx context.Context, storage logical.Storage) (time.Time, error) {
+	config, err := schedule.GetConfig(ctx, storage)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if config == nil {
+		return time.Time{}, nil
+	}
+
+	s, err := schedule.Parse(config)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return s.NextAllowedTime(systemClock)
+}
+
 // checkExceedingInterval returns true if more than interval were spent
 func checkExceedingInterval(ctx context.Context, storage logical.Storage, interval time.Duration) (bool, error) {
 	result := false
@@ -176,6 +711,121 @@ func storeLastFinishedCommit(ctx context.Context, storage logical.Storage, commi
 	return util.PutJSON(ctx, storage, storageKeyLastFinishedCommit, commitInfo)
 }
 
+func storeLastAppliedCommit(ctx context.Context, storage logical.Storage, commitInfo *LastFinishedCommit) error {
+	return util.PutJSON(ctx, storage, storageKeyLastAppliedCommit, commitInfo)
+}
+
+// migrateLastAppliedCommit stores the last finished commit as the last applied one if the workspace
+// finished commits before applied commits were tracked: every finished commit was applied then
+func migrateLastAppliedCommit(ctx context.Context, storage logical.Storage) error {
+	entry, err := storage.Get(ctx, storageKeyLastAppliedCommit)
+	if err != nil || entry != nil {
+		return err
+	}
+
+	var lastFinishedCommit *LastFinishedCommit
+	if err := util.GetJSON(ctx, storage, storageKeyLastFinishedCommit, &lastFinishedCommit); err != nil {
+		return err
+	}
+	if lastFinishedCommit == nil {
+		return nil
+	}
+
+	return storeLastAppliedCommit(ctx, storage, lastFinishedCommit)
+}
+
+// getLastAppliedCommit returns the commit terraform state corresponds to,
+// the last finished commit if it is not migrated yet, see migrateLastAppliedCommit
+func getLastAppliedCommit(ctx context.Context, storage logical.Storage) (*LastFinishedCommit, error) {
+	var commitInfo *LastFinishedCommit
+	if err := util.GetJSON(ctx, storage, storageKeyLastAppliedCommit, &commitInfo); err != nil {
+		return nil, err
+	}
+	if commitInfo != nil {
+		return commitInfo, nil
+	}
+
+	if err := util.GetJSON(ctx, storage, storageKeyLastFinishedCommit, &commitInfo); err != nil {
+		return nil, err
+	}
+	return commitInfo, nil
+}
+
+// recordHeadCommit stores HEAD of the branch, a new unsigned HEAD is notified once
+func (b *backend) recordHeadCommit(ctx context.Context, storage logical.Storage, workspace string, head *git_repository.HeadCommit) {
+	var previous *HeadCommit
+	if err := util.GetJSON(ctx, storage, storageKeyHeadCommit, &previous); err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to get HEAD commit: %v", err))
+		return
+	}
+
+	if err := util.PutJSON(ctx, storage, storageKeyHeadCommit, &HeadCommit{
+		CommitHash: head.CommitHash,
+		CommitDate: head.CommitDate,
+		Signed:     head.Signed,
+		SeenAt:     systemClock.Now(),
+	}); err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to store HEAD commit: %v", err))
+		return
+	}
+
+	if !head.Signed && (previous == nil || previous.CommitHash != head.CommitHash) {
+		b.notify(storage, &notifications.Event{
+			Type:       notifications.EventUnsignedHead,
+			Workspace:  workspace,
+			CommitHash: head.CommitHash,
+			Message:    "HEAD of the branch does not have the required number of verified signatures",
+		})
+	}
+}
+
+// rejectedCommits are hashes of commits reported as rejected for insufficient verified signatures
+type rejectedCommits struct {
+	reported map[string]bool
+	seen     []string
+	// complete is set when the search has walked the whole range, reported commits not seen again are out of it
+	complete bool
+}
+
+// add returns true if the commit is not reported yet
+func (r *rejectedCommits) add(commitHash string) bool {
+	r.seen = append(r.seen, commitHash)
+	if r.reported[commitHash] {
+		return false
+	}
+	r.reported[commitHash] = true
+	return true
+}
+
+func (b *backend) loadRejectedCommits(ctx context.Context, storage logical.Storage) *rejectedCommits {
+	var hashes []string
+	if err := util.GetJSON(ctx, storage, storageKeyRejectedCommits, &hashes); err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to get rejected commits: %v", err))
+	}
+
+	rejected := &rejectedCommits{reported: map[string]bool{}}
+	for _, hash := range hashes {
+		rejected.reported[hash] = true
+	}
+	return rejected
+}
+
+// storeRejectedCommits keeps only the commits of the search range after a complete search
+func (b *backend) storeRejectedCommits(ctx context.Context, storage logical.Storage, rejected *rejectedCommits) {
+	hashes := rejected.seen
+	if !rejected.complete {
+		hashes = nil
+		for hash := range rejected.reported {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+	}
+
+	if err := util.PutJSON(context.WithoutCancel(ctx), storage, storageKeyRejectedCommits, hashes); err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to store rejected commits: %v", err))
+	}
+}
+
 func storeProcessStatusCommit(ctx context.Context, storage logical.Storage, status string) error {
 	return util.PutString(ctx, storage, storageKeyProcessStatus, status)
 }
@@ -0,0 +1,56 @@
+package gitops_terraform
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/directives"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/drift"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+func Test_applyCommit_SkippedCommitIsNotApplied(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	logicalBackend, err := Factory(ctx, logical.TestBackendConfig())
+	require.NoError(t, err)
+	b := logicalBackend.(*backend)
+
+	// Finished before applied commits were tracked
+	applied := &LastFinishedCommit{CommitHash: "1111111111111111111111111111111111111111", CommitDate: time.Now().Add(-time.Hour)}
+	require.NoError(t, storeLastFinishedCommit(ctx, storage, applied))
+
+	opts := processGitOptions{RunID: "run-1", Trigger: triggerManual}
+	tracker := b.newRunTracker(ctx, storage, opts)
+	skipped := &git_repository.CommitInfo{
+		CommitHash: "2222222222222222222222222222222222222222",
+		CommitDate: time.Now(),
+		Directives: []directives.Directive{{Key: directives.KeySkip, Value: "true"}},
+	}
+
+	ok, err := b.applyCommit(ctx, storage, tracker, opts, skipped)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, runs.StatusSkipped, tracker.run.Status)
+
+	var lastFinished *LastFinishedCommit
+	require.NoError(t, util.GetJSON(ctx, storage, storageKeyLastFinishedCommit, &lastFinished))
+	require.Equal(t, skipped.CommitHash, lastFinished.CommitHash, "skipped commit is finished")
+
+	lastApplied, err := getLastAppliedCommit(ctx, storage)
+	require.NoError(t, err)
+	require.Equal(t, applied.CommitHash, lastApplied.CommitHash, "skipped commit is not applied")
+
+	// Drift check plans the applied commit, the repository is not configured so planning fails
+	require.NoError(t, util.PutJSON(ctx, storage, drift.StorageKeyConfiguration, drift.Configuration{CheckPeriod: time.Hour}))
+	err = b.checkDrift(ctx, storage, tracker, opts, &git_repository.CommitInfo{CommitHash: lastApplied.CommitHash, CommitDate: lastApplied.CommitDate})
+	require.ErrorContains(t, err, applied.CommitHash)
+	require.Equal(t, applied.CommitHash, tracker.run.CommitHash)
+}
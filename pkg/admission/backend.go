@@ -0,0 +1,226 @@
+package admission
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameURL           = "url"
+	FieldNameCACertificate = "ca_certificate"
+	FieldNameTimeout       = "timeout"
+	FieldNameSecret        = "secret"
+	FieldNameFailOpen      = "fail_open"
+
+	StorageKeyConfiguration = "admission_webhook_configuration"
+
+	defaultTimeout = 10 * time.Second
+)
+
+type Configuration struct {
+	URL           string        `structs:"url" json:"url"`
+	CACertificate string        `structs:"ca_certificate" json:"ca_certificate"`
+	Timeout       time.Duration `structs:"timeout" json:"timeout"`
+	Secret        string        `structs:"secret" json:"secret"`
+	FailOpen      bool          `structs:"fail_open" json:"fail_open"`
+}
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^configure/admission_webhook/?$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameURL: {
+					Type:        framework.TypeString,
+					Description: "URL the plan is sent to before apply. Required for create.",
+				},
+				FieldNameCACertificate: {
+					Type:        framework.TypeString,
+					Description: "PEM encoded CA bundle to verify the webhook server certificate.",
+				},
+				FieldNameTimeout: {
+					Type:        framework.TypeDurationSecond,
+					Default:     int(defaultTimeout.Seconds()),
+					Description: "Timeout of the webhook request.",
+				},
+				FieldNameSecret: {
+					Type:        framework.TypeString,
+					Description: "Shared secret to sign the request body, sent as HMAC-SHA256 in the X-Gitops-Signature header.",
+				},
+				FieldNameFailOpen: {
+					Type:        framework.TypeBool,
+					Default:     false,
+					Description: "Allow the plan if the webhook fails or responds with an error. Default is false (deny).",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Create admission webhook configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Update the current admission webhook configuration.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathConfigureRead,
+					Summary:  "Read the current admission webhook configuration.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathConfigureDelete,
+					Summary:  "Delete the current admission webhook configuration.",
+				},
+			},
+			ExistenceCheck:  b.pathConfigExistenceCheck,
+			HelpSynopsis:    configureHelpSyn,
+			HelpDescription: configureHelpDesc,
+		},
+	}
+}
+
+// pathConfigExistenceCheck verifies if the configuration exists.
+func (b *backend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Admission webhook configuration started")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get existing configuration: %s", err), nil
+	}
+	if config == nil {
+		config = &Configuration{Timeout: defaultTimeout}
+	}
+
+	if webhookURL, ok := fields.GetOk(FieldNameURL); ok {
+		config.URL = webhookURL.(string)
+	}
+	if caCertificate, ok := fields.GetOk(FieldNameCACertificate); ok {
+		config.CACertificate = caCertificate.(string)
+	}
+	if timeout, ok := fields.GetOk(FieldNameTimeout); ok {
+		config.Timeout = time.Duration(timeout.(int)) * time.Second
+	}
+	if secret, ok := fields.GetOk(FieldNameSecret); ok {
+		config.Secret = secret.(string)
+	}
+	if failOpen, ok := fields.GetOk(FieldNameFailOpen); ok {
+		config.FailOpen = failOpen.(bool)
+	}
+
+	parsedURL, err := url.Parse(config.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return logical.ErrorResponse("%q field value should be http or https URL", FieldNameURL), nil
+	}
+	if config.Timeout <= 0 {
+		return logical.ErrorResponse("%q field value should be positive", FieldNameTimeout), nil
+	}
+	if _, err := newHTTPClient(config); err != nil {
+		return logical.ErrorResponse("%q field is invalid: %s", FieldNameCACertificate, err), nil
+	}
+
+	if err := putConfiguration(ctx, req.Storage, *config); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigureRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Reading admission webhook configuration")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get Configuration: %s", err), nil
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	// Return whether the secret is set, not the secret
+	return &logical.Response{Data: map[string]interface{}{
+		FieldNameURL:           config.URL,
+		FieldNameCACertificate: config.CACertificate,
+		FieldNameTimeout:       config.Timeout.Seconds(),
+		FieldNameFailOpen:      config.FailOpen,
+		"secret_set":           config.Secret != "",
+	}}, nil
+}
+
+func (b *backend) pathConfigureDelete(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Debug("Deleting admission webhook configuration")
+
+	if err := req.Storage.Delete(ctx, StorageKeyConfiguration); err != nil {
+		return logical.ErrorResponse("Unable to delete Configuration: %s", err), nil
+	}
+
+	return nil, nil
+}
+
+func putConfiguration(ctx context.Context, storage logical.Storage, config Configuration) error {
+	storageEntry, err := logical.StorageEntryJSON(StorageKeyConfiguration, config)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, storageEntry)
+}
+
+// GetConfig returns nil if the admission webhook is not configured
+func GetConfig(ctx context.Context, storage logical.Storage) (*Configuration, error) {
+	storageEntry, err := storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get Configuration: %w", err)
+	}
+	if storageEntry == nil {
+		return nil, nil
+	}
+
+	var config *Configuration
+	if err := storageEntry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+const (
+	configureHelpSyn = `
+External admission webhook deciding whether the plan may be applied.
+`
+	configureHelpDesc = `
+Before apply the plugin POSTs the workspace, the commit hash, names of trusted
+keys which signed the commit and the plan in "terraform show -json" format to
+the url. The service responds with {"allowed": true|false, "message": "..."}.
+
+If the request fails, times out or the response is not 200 OK, the plan is
+denied unless fail_open is set. With a secret the request body is signed with
+HMAC-SHA256, the hex digest is sent as "X-Gitops-Signature: sha256=<digest>".
+`
+)
@@ -0,0 +1,137 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+const (
+	headerSignature = "X-Gitops-Signature"
+
+	// maxResponseSize limits the response body read from the webhook
+	maxResponseSize = 1 << 20
+)
+
+// Request is sent to the webhook before apply
+type Request struct {
+	Workspace  string          `json:"workspace"`
+	CommitHash string          `json:"commit_hash"`
+	Signers    []string        `json:"signers"`
+	Plan       json.RawMessage `json:"plan"`
+}
+
+// response of the webhook
+type response struct {
+	Allowed *bool  `json:"allowed"`
+	Message string `json:"message"`
+}
+
+// DeniedError is returned when the webhook denies the plan or fails without fail_open
+type DeniedError struct {
+	Decision *runs.AdmissionDecision
+}
+
+func (e *DeniedError) Error() string {
+	if e.Decision.Error != "" {
+		return fmt.Sprintf("admission webhook failed: %s", e.Decision.Error)
+	}
+	return fmt.Sprintf("plan denied by admission webhook: %s", e.Decision.Message)
+}
+
+// Review sends the request to the webhook and returns its decision.
+// A failure of the webhook is a decision too, it allows the plan only with fail_open
+func Review(ctx context.Context, config *Configuration, request *Request, now time.Time) *runs.AdmissionDecision {
+	decision := &runs.AdmissionDecision{URL: config.URL, DecidedAt: now}
+
+	resp, err := send(ctx, config, request)
+	if err != nil {
+		decision.Allowed = config.FailOpen
+		decision.Error = err.Error()
+		return decision
+	}
+
+	decision.Allowed = *resp.Allowed
+	decision.Message = resp.Message
+	return decision
+}
+
+func send(ctx context.Context, config *Configuration, request *Request) (*response, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal request: %w", err)
+	}
+
+	client, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if config.Secret != "" {
+		httpRequest.Header.Set(headerSignature, "sha256="+Sign(config.Secret, body))
+	}
+
+	httpResponse, err := client.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response: %w", err)
+	}
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status %d: %s", httpResponse.StatusCode, bytes.TrimSpace(data))
+	}
+
+	var resp response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal response: %w", err)
+	}
+	if resp.Allowed == nil {
+		return nil, errors.New(`response has no "allowed" field`)
+	}
+
+	return &resp, nil
+}
+
+// Sign returns hex HMAC-SHA256 of the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newHTTPClient(config *Configuration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CACertificate != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(config.CACertificate)) {
+			return nil, errors.New("no PEM certificates found")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,93 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Review(t *testing.T) {
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	request := &Request{
+		Workspace:  "prod",
+		CommitHash: "a",
+		Signers:    []string{"alice"},
+		Plan:       json.RawMessage(`{"resource_changes":[]}`),
+	}
+
+	tests := []struct {
+		description     string
+		failOpen        bool
+		status          int
+		body            string
+		expectedAllowed bool
+		expectedMessage string
+		expectedError   bool
+	}{
+		{
+			description:     "allowed",
+			status:          http.StatusOK,
+			body:            `{"allowed": true, "message": "ok"}`,
+			expectedAllowed: true,
+			expectedMessage: "ok",
+		},
+		{
+			description:     "denied",
+			status:          http.StatusOK,
+			body:            `{"allowed": false, "message": "change window is closed"}`,
+			expectedMessage: "change window is closed",
+		},
+		{
+			description:   "server error denies by default",
+			status:        http.StatusInternalServerError,
+			body:          `oops`,
+			expectedError: true,
+		},
+		{
+			description:     "server error with fail open",
+			failOpen:        true,
+			status:          http.StatusInternalServerError,
+			body:            `oops`,
+			expectedAllowed: true,
+			expectedError:   true,
+		},
+		{
+			description:   "response without decision",
+			status:        http.StatusOK,
+			body:          `{"message": "ok"}`,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, "sha256="+Sign("secret", body), r.Header.Get(headerSignature))
+
+				var received Request
+				require.NoError(t, json.Unmarshal(body, &received))
+				require.Equal(t, *request, received)
+
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			config := &Configuration{URL: server.URL, Timeout: time.Second, Secret: "secret", FailOpen: tt.failOpen}
+			decision := Review(context.Background(), config, request, now)
+
+			require.Equal(t, tt.expectedAllowed, decision.Allowed)
+			require.Equal(t, tt.expectedMessage, decision.Message)
+			require.Equal(t, tt.expectedError, decision.Error != "", decision.Error)
+			require.Equal(t, now, decision.DecidedAt)
+		})
+	}
+}
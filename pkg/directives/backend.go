@@ -0,0 +1,159 @@
+package directives
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameAllowedDirectives = "allowed_directives"
+
+	StorageKeyConfiguration = "commit_directives_configuration"
+)
+
+type Configuration struct {
+	AllowedDirectives []string `structs:"allowed_directives" json:"allowed_directives"`
+}
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^configure/commit_directives/?$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameAllowedDirectives: {
+					Type:        framework.TypeCommaStringSlice,
+					Default:     KnownKeys,
+					Description: "Directives allowed in commit messages: Gitops-Mode, Gitops-Skip, Gitops-Target, Gitops-Refresh-Only. Empty list disallows all.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Create commit directives configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Update the current commit directives configuration.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathConfigureRead,
+					Summary:  "Read the current commit directives configuration.",
+				},
+			},
+			ExistenceCheck:  b.pathConfigExistenceCheck,
+			HelpSynopsis:    configureHelpSyn,
+			HelpDescription: configureHelpDesc,
+		},
+	}
+}
+
+// pathConfigExistenceCheck verifies if the configuration exists.
+func (b *backend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Commit directives configuration started")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get existing configuration: %s", err), nil
+	}
+
+	if allowedDirectives, ok := fields.GetOk(FieldNameAllowedDirectives); ok {
+		config.AllowedDirectives = nil
+		for _, key := range allowedDirectives.([]string) {
+			canonical, ok := canonicalKey(key, KnownKeys)
+			if !ok {
+				return logical.ErrorResponse("%q field has unknown directive %q", FieldNameAllowedDirectives, key), nil
+			}
+			config.AllowedDirectives = append(config.AllowedDirectives, canonical)
+		}
+	}
+
+	if err := putConfiguration(ctx, req.Storage, *config); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigureRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Reading commit directives configuration")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get Configuration: %s", err), nil
+	}
+
+	return &logical.Response{Data: map[string]interface{}{
+		FieldNameAllowedDirectives: config.AllowedDirectives,
+	}}, nil
+}
+
+func putConfiguration(ctx context.Context, storage logical.Storage, config Configuration) error {
+	storageEntry, err := logical.StorageEntryJSON(StorageKeyConfiguration, config)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, storageEntry)
+}
+
+// GetConfig returns the commit directives configuration, all directives are allowed if it is not set
+func GetConfig(ctx context.Context, storage logical.Storage) (*Configuration, error) {
+	storageEntry, err := storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get Configuration: %w", err)
+	}
+	if storageEntry == nil {
+		return &Configuration{AllowedDirectives: KnownKeys}, nil
+	}
+
+	var config *Configuration
+	if err := storageEntry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+const (
+	configureHelpSyn = `
+Directives in commit messages which change how the commit is processed.
+`
+	configureHelpDesc = `
+Directives are trailers of the commit message, covered by the commit
+signatures:
+
+  Gitops-Mode: plan-only       plan the commit without applying it
+  Gitops-Skip: true            mark the commit processed without running terraform
+  Gitops-Target: <address>     limit plan and apply to the resource, may be repeated
+  Gitops-Refresh-Only: true    only update the state from the real resources
+
+A commit with an unknown, malformed or not allowed directive fails and is
+quarantined.
+`
+)
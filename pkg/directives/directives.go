@@ -0,0 +1,136 @@
+package directives
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Keys of directives, matched case-insensitively like git trailers
+const (
+	KeyMode        = "Gitops-Mode"
+	KeySkip        = "Gitops-Skip"
+	KeyTarget      = "Gitops-Target"
+	KeyRefreshOnly = "Gitops-Refresh-Only"
+
+	keyPrefix = "gitops-"
+)
+
+// Values of Gitops-Mode
+const (
+	ModeApply    = "apply"
+	ModePlanOnly = "plan-only"
+)
+
+// KnownKeys are all supported directives
+var KnownKeys = []string{KeyMode, KeySkip, KeyTarget, KeyRefreshOnly}
+
+var trailerRegex = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*):\s*(.*)$`)
+
+// Directive is a Gitops-* trailer of the commit message
+type Directive struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Options describe how the commit is processed
+type Options struct {
+	Skip        bool     `json:"skip,omitempty"`
+	PlanOnly    bool     `json:"plan_only,omitempty"`
+	Targets     []string `json:"targets,omitempty"`
+	RefreshOnly bool     `json:"refresh_only,omitempty"`
+}
+
+// InvalidError is returned for unknown, disallowed or malformed directives
+type InvalidError struct {
+	Problems []string
+}
+
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("invalid commit directives: %s", strings.Join(e.Problems, "; "))
+}
+
+// Parse returns Gitops-* trailers of the commit message. Trailers are lines "Key: value" of the last
+// paragraph, which is not the subject, and where every line is a trailer
+func Parse(message string) []Directive {
+	paragraphs := strings.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+
+	var result []Directive
+	for _, line := range strings.Split(strings.TrimSpace(paragraphs[len(paragraphs)-1]), "\n") {
+		match := trailerRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			return nil
+		}
+		if strings.HasPrefix(strings.ToLower(match[1]), keyPrefix) {
+			result = append(result, Directive{Key: match[1], Value: strings.TrimSpace(match[2])})
+		}
+	}
+	return result
+}
+
+// Resolve validates directives against the allowed keys and returns processing options
+func Resolve(list []Directive, allowedKeys []string) (*Options, error) {
+	options := &Options{}
+	var problems []string
+	seen := map[string]bool{}
+
+	for _, directive := range list {
+		key, ok := canonicalKey(directive.Key, KnownKeys)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown directive %q", directive.Key))
+			continue
+		}
+		if _, ok := canonicalKey(key, allowedKeys); !ok {
+			problems = append(problems, fmt.Sprintf("directive %q is not allowed", key))
+			continue
+		}
+		if seen[key] && key != KeyTarget {
+			problems = append(problems, fmt.Sprintf("directive %q is repeated", key))
+			continue
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case KeyMode:
+			switch directive.Value {
+			case ModeApply:
+			case ModePlanOnly:
+				options.PlanOnly = true
+			default:
+				err = fmt.Errorf("should be %q or %q", ModeApply, ModePlanOnly)
+			}
+		case KeySkip:
+			options.Skip, err = strconv.ParseBool(directive.Value)
+		case KeyTarget:
+			if directive.Value == "" {
+				err = fmt.Errorf("resource address should not be empty")
+			}
+			options.Targets = append(options.Targets, directive.Value)
+		case KeyRefreshOnly:
+			options.RefreshOnly, err = strconv.ParseBool(directive.Value)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("directive %q value %q is invalid: %s", key, directive.Value, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &InvalidError{Problems: problems}
+	}
+	return options, nil
+}
+
+// canonicalKey returns the key from keys equal to the given one ignoring case
+func canonicalKey(key string, keys []string) (string, bool) {
+	for _, k := range keys {
+		if strings.EqualFold(k, key) {
+			return k, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,110 @@
+package directives
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		description string
+		message     string
+		expected    []Directive
+	}{
+		{
+			description: "trailers of the last paragraph",
+			message:     "Rotate admin policy\n\nDetails.\n\nGitops-Target: vault_policy.admin\nSigned-off-by: Alice <alice@example.com>\ngitops-mode: plan-only\n",
+			expected: []Directive{
+				{Key: "Gitops-Target", Value: "vault_policy.admin"},
+				{Key: "gitops-mode", Value: "plan-only"},
+			},
+		},
+		{
+			description: "subject is not a trailer",
+			message:     "Gitops-Skip: true\n",
+		},
+		{
+			description: "paragraph with text is not a trailer block",
+			message:     "Update\n\nGitops-Skip: true\nbecause nothing changes\n",
+		},
+		{
+			description: "trailers not in the last paragraph",
+			message:     "Update\n\nGitops-Skip: true\n\nMore text.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			require.Equal(t, tt.expected, Parse(tt.message))
+		})
+	}
+}
+
+func Test_Resolve(t *testing.T) {
+	tests := []struct {
+		description     string
+		directives      []Directive
+		allowed         []string
+		expected        *Options
+		expectedProblem string
+	}{
+		{
+			description: "no directives",
+			allowed:     KnownKeys,
+			expected:    &Options{},
+		},
+		{
+			description: "targets and refresh only",
+			directives: []Directive{
+				{Key: "gitops-target", Value: "vault_policy.admin"},
+				{Key: "Gitops-Target", Value: "vault_mount.kv"},
+				{Key: "Gitops-Refresh-Only", Value: "true"},
+			},
+			allowed:  KnownKeys,
+			expected: &Options{Targets: []string{"vault_policy.admin", "vault_mount.kv"}, RefreshOnly: true},
+		},
+		{
+			description: "plan only",
+			directives:  []Directive{{Key: "Gitops-Mode", Value: "plan-only"}},
+			allowed:     KnownKeys,
+			expected:    &Options{PlanOnly: true},
+		},
+		{
+			description:     "unknown directive",
+			directives:      []Directive{{Key: "Gitops-Force", Value: "true"}},
+			allowed:         KnownKeys,
+			expectedProblem: `unknown directive "Gitops-Force"`,
+		},
+		{
+			description:     "not allowed directive",
+			directives:      []Directive{{Key: "Gitops-Skip", Value: "true"}},
+			allowed:         []string{KeyMode},
+			expectedProblem: `directive "Gitops-Skip" is not allowed`,
+		},
+		{
+			description:     "invalid mode",
+			directives:      []Directive{{Key: "Gitops-Mode", Value: "destroy"}},
+			allowed:         KnownKeys,
+			expectedProblem: `directive "Gitops-Mode" value "destroy" is invalid`,
+		},
+		{
+			description:     "repeated directive",
+			directives:      []Directive{{Key: "Gitops-Skip", Value: "true"}, {Key: "Gitops-Skip", Value: "false"}},
+			allowed:         KnownKeys,
+			expectedProblem: `directive "Gitops-Skip" is repeated`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			options, err := Resolve(tt.directives, tt.allowed)
+			if tt.expectedProblem != "" {
+				require.ErrorContains(t, err, tt.expectedProblem)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, options)
+		})
+	}
+}
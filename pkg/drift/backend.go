@@ -0,0 +1,163 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameCheckPeriod = "drift_check_period"
+	FieldNameRemediation = "drift_remediation"
+
+	StorageKeyConfiguration = "drift_configuration"
+)
+
+type Configuration struct {
+	CheckPeriod time.Duration `structs:"drift_check_period" json:"drift_check_period"`
+	Remediation bool          `structs:"drift_remediation" json:"drift_remediation"`
+}
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^configure/drift/?$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameCheckPeriod: {
+					Type:        framework.TypeDurationSecond,
+					Default:     0,
+					Description: "Period between drift checks of the last finished commit. 0 disables drift detection.",
+				},
+				FieldNameRemediation: {
+					Type:        framework.TypeBool,
+					Default:     false,
+					Description: "Apply the last finished commit when drift is detected.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Create drift detection configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Update the current drift detection configuration.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathConfigureRead,
+					Summary:  "Read the current drift detection configuration.",
+				},
+			},
+			ExistenceCheck:  b.pathConfigExistenceCheck,
+			HelpSynopsis:    configureHelpSyn,
+			HelpDescription: configureHelpDesc,
+		},
+	}
+}
+
+// pathConfigExistenceCheck verifies if the configuration exists.
+func (b *backend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Drift detection configuration started")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get existing configuration: %s", err), nil
+	}
+
+	if checkPeriod, ok := fields.GetOk(FieldNameCheckPeriod); ok {
+		config.CheckPeriod = time.Duration(checkPeriod.(int)) * time.Second
+	}
+
+	if remediation, ok := fields.GetOk(FieldNameRemediation); ok {
+		config.Remediation = remediation.(bool)
+	}
+
+	if config.CheckPeriod < 0 {
+		return logical.ErrorResponse("%q field value should not be negative", FieldNameCheckPeriod), nil
+	}
+
+	if err := putConfiguration(ctx, req.Storage, *config); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigureRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Reading drift detection configuration")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get Configuration: %s", err), nil
+	}
+
+	return &logical.Response{Data: map[string]interface{}{
+		FieldNameCheckPeriod: config.CheckPeriod.Seconds(),
+		FieldNameRemediation: config.Remediation,
+	}}, nil
+}
+
+func putConfiguration(ctx context.Context, storage logical.Storage, config Configuration) error {
+	storageEntry, err := logical.StorageEntryJSON(StorageKeyConfiguration, config)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, storageEntry)
+}
+
+// GetConfig returns the drift detection configuration, drift detection is disabled if it is not set
+func GetConfig(ctx context.Context, storage logical.Storage) (*Configuration, error) {
+	storageEntry, err := storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get Configuration: %w", err)
+	}
+	if storageEntry == nil {
+		return &Configuration{}, nil
+	}
+
+	var config *Configuration
+	if err := storageEntry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+const (
+	configureHelpSyn = `
+Drift detection configuration of the gitops_terraform backend.
+`
+	configureHelpDesc = `
+Every drift_check_period the last finished commit is planned again when there
+is no new signed commit. Resources changed outside of the repository are
+reported by the status endpoint and the run history. With drift_remediation
+the last finished commit is applied to revert the changes.
+`
+)
@@ -0,0 +1,57 @@
+package drift
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	storageKeyResult             = "drift_result"
+	storageKeyLastCheckTimestamp = "last_drift_check_timestamp"
+)
+
+// Result is the outcome of the last drift check
+type Result struct {
+	CommitHash string                `json:"commit_hash"`
+	CheckedAt  time.Time             `json:"checked_at"`
+	Changes    []runs.ResourceChange `json:"changes"`
+	Remediated bool                  `json:"remediated"`
+}
+
+func PutResult(ctx context.Context, storage logical.Storage, result *Result) error {
+	return util.PutJSON(ctx, storage, storageKeyResult, result)
+}
+
+// GetResult returns nil if drift was never checked
+func GetResult(ctx context.Context, storage logical.Storage) (*Result, error) {
+	var result *Result
+	if err := util.GetJSON(ctx, storage, storageKeyResult, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// IsCheckDue returns true if the check period has passed since the last check and marks the check as started
+func IsCheckDue(ctx context.Context, storage logical.Storage, config *Configuration, now time.Time) (bool, error) {
+	if config.CheckPeriod == 0 {
+		return false, nil
+	}
+
+	lastCheck, err := util.GetInt64(ctx, storage, storageKeyLastCheckTimestamp)
+	if err != nil {
+		return false, err
+	}
+	if now.Sub(time.Unix(lastCheck, 0)) < config.CheckPeriod {
+		return false, nil
+	}
+
+	if err := util.PutInt64(ctx, storage, storageKeyLastCheckTimestamp, now.Unix()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
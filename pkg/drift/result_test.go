@@ -0,0 +1,34 @@
+package drift
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_IsCheckDue(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	due, err := IsCheckDue(ctx, storage, &Configuration{}, now)
+	require.NoError(t, err)
+	require.False(t, due, "disabled check is never due")
+
+	config := &Configuration{CheckPeriod: time.Hour}
+
+	due, err = IsCheckDue(ctx, storage, config, now)
+	require.NoError(t, err)
+	require.True(t, due, "first check is due at once")
+
+	due, err = IsCheckDue(ctx, storage, config, now.Add(30*time.Minute))
+	require.NoError(t, err)
+	require.False(t, due)
+
+	due, err = IsCheckDue(ctx, storage, config, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, due)
+}
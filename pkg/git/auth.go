@@ -0,0 +1,129 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitHTTP "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// AuthMethod returns auth of the clone operation, nil if credentials are not configured
+func (c *GitCredential) AuthMethod() (transport.AuthMethod, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	switch c.Type {
+	case CredentialTypeSSH:
+		return c.sshAuth()
+	case CredentialTypeToken:
+		return &headerAuth{header: c.TokenHeader, token: c.Token}, nil
+	default:
+		if c.Username == "" || c.Password == "" {
+			return nil, nil
+		}
+		return &gitHTTP.BasicAuth{Username: c.Username, Password: c.Password}, nil
+	}
+}
+
+// sshAuth returns public keys auth which accepts only server keys pinned in SSHKnownHosts
+func (c *GitCredential) sshAuth() (*gitSSH.PublicKeys, error) {
+	auth, err := gitSSH.NewPublicKeys(c.Username, []byte(c.SSHPrivateKey), c.SSHPassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
+	}
+
+	algorithms, err := knownHostsAlgorithms(c.SSHKnownHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	callback, err := knownHostsCallback(c.SSHKnownHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	auth.HostKeyCallback = callback
+	auth.HostKeyAlgorithms = algorithms
+	return auth, nil
+}
+
+// knownHostsCallback verifies server keys against known_hosts lines, the format is read from a temporary file
+func knownHostsCallback(knownHosts string) (ssh.HostKeyCallback, error) {
+	file, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create known_hosts file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(knownHosts); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to write known_hosts file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write known_hosts file: %w", err)
+	}
+
+	callback, err := knownhosts.New(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("invalid known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// knownHostsAlgorithms returns host key algorithms of the pinned keys, so the server presents one of them
+func knownHostsAlgorithms(knownHosts string) ([]string, error) {
+	var algorithms []string
+	rest := []byte(knownHosts)
+	for {
+		var key ssh.PublicKey
+		var err error
+		_, _, key, _, rest, err = ssh.ParseKnownHosts(rest)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid known_hosts: %w", err)
+		}
+
+		if key.Type() == ssh.KeyAlgoRSA {
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algorithms = append(algorithms, key.Type())
+	}
+
+	if len(algorithms) == 0 {
+		return nil, fmt.Errorf("invalid known_hosts: no host keys")
+	}
+	return algorithms, nil
+}
+
+// headerAuth sends the token in the HTTP header, as bearer token in the Authorization header
+type headerAuth struct {
+	header string
+	token  string
+}
+
+func (a *headerAuth) SetAuth(r *http.Request) {
+	if strings.EqualFold(a.header, defaultTokenHeader) {
+		r.Header.Set(defaultTokenHeader, "Bearer "+a.token)
+		return
+	}
+	r.Header.Set(a.header, a.token)
+}
+
+func (a *headerAuth) Name() string {
+	return "http-token-header-auth"
+}
+
+func (a *headerAuth) String() string {
+	return fmt.Sprintf("%s - %s: <masked>", a.Name(), a.header)
+}
@@ -3,21 +3,48 @@ package git
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
 const (
-	FieldNameGitCredentialUsername = "username"
-	FieldNameGitCredentialPassword = "password"
+	FieldNameGitCredentialType          = "type"
+	FieldNameGitCredentialUsername      = "username"
+	FieldNameGitCredentialPassword      = "password"
+	FieldNameGitCredentialSSHPrivateKey = "ssh_private_key"
+	FieldNameGitCredentialSSHPassphrase = "ssh_passphrase"
+	FieldNameGitCredentialSSHKnownHosts = "ssh_known_hosts"
+	FieldNameGitCredentialToken         = "token"
+	FieldNameGitCredentialTokenHeader   = "token_header"
 
 	StorageKeyConfigurationGitCredential = "configuration_git_credential"
 )
 
+// Types of Git credentials
+const (
+	CredentialTypeBasic = "basic"
+	CredentialTypeSSH   = "ssh"
+	CredentialTypeToken = "token"
+
+	defaultSSHUsername = "git"
+	defaultTokenHeader = "Authorization"
+)
+
 type GitCredential struct {
+	// Type is empty for credentials stored before types were introduced, it means basic
+	Type     string `structs:"type" json:"type,omitempty"`
 	Username string `structs:"username" json:"username"`
 	Password string `structs:"password" json:"password"`
+	// SSHPrivateKey is PEM encoded, SSHPassphrase decrypts it if the key is encrypted
+	SSHPrivateKey string `structs:"ssh_private_key" json:"ssh_private_key,omitempty"`
+	SSHPassphrase string `structs:"ssh_passphrase" json:"ssh_passphrase,omitempty"`
+	// SSHKnownHosts are known_hosts lines the server key is verified against
+	SSHKnownHosts string `structs:"ssh_known_hosts" json:"ssh_known_hosts,omitempty"`
+	// Token is sent as bearer token in the Authorization header or as is in TokenHeader
+	Token       string `structs:"token" json:"token,omitempty"`
+	TokenHeader string `structs:"token_header" json:"token_header,omitempty"`
 }
 
 func CredentialsPaths() []*framework.Path {
@@ -25,16 +52,42 @@ func CredentialsPaths() []*framework.Path {
 		{
 			Pattern:         "^configure/git_credential/?$",
 			HelpSynopsis:    "Configure Git credentials",
-			HelpDescription: "Configure Git credentials to perform clone operation",
+			HelpDescription: credentialsHelpDesc,
 
 			Fields: map[string]*framework.FieldSchema{
+				FieldNameGitCredentialType: {
+					Type:        framework.TypeString,
+					Default:     CredentialTypeBasic,
+					Description: "Type of credentials: basic (username and password), ssh (private key) or token (HTTP header).",
+				},
 				FieldNameGitCredentialUsername: {
 					Type:        framework.TypeString,
-					Description: "A Git username; Required for CREATE, UPDATE.",
+					Description: "A Git username; Required for basic, defaults to git for ssh.",
 				},
 				FieldNameGitCredentialPassword: {
 					Type:        framework.TypeString,
-					Description: "A Git password; Required for CREATE, UPDATE.",
+					Description: "A Git password; Required for basic.",
+				},
+				FieldNameGitCredentialSSHPrivateKey: {
+					Type:        framework.TypeString,
+					Description: "PEM encoded SSH private key; Required for ssh.",
+				},
+				FieldNameGitCredentialSSHPassphrase: {
+					Type:        framework.TypeString,
+					Description: "Passphrase of the SSH private key.",
+				},
+				FieldNameGitCredentialSSHKnownHosts: {
+					Type:        framework.TypeString,
+					Description: "known_hosts lines with the pinned key of the Git server; Required for ssh.",
+				},
+				FieldNameGitCredentialToken: {
+					Type:        framework.TypeString,
+					Description: "Access token; Required for token.",
+				},
+				FieldNameGitCredentialTokenHeader: {
+					Type:        framework.TypeString,
+					Default:     defaultTokenHeader,
+					Description: "HTTP header of the token. The token is sent as \"Bearer <token>\" in the Authorization header and as is in any other header.",
 				},
 			},
 
@@ -47,6 +100,10 @@ func CredentialsPaths() []*framework.Path {
 					Description: "Configure Git credentials",
 					Callback:    pathConfigureGitCredentialCreateOrUpdate,
 				},
+				logical.ReadOperation: &framework.PathOperation{
+					Description: "Read Git credentials configuration without secrets",
+					Callback:    pathConfigureGitCredentialRead,
+				},
 				logical.DeleteOperation: &framework.PathOperation{
 					Description: "Reset Git credentials",
 					Callback:    pathConfigureGitCredentialDelete,
@@ -69,15 +126,52 @@ func pathConfigExistenceCheck(ctx context.Context, req *logical.Request, fields
 
 func pathConfigureGitCredentialCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
 	gitCredential := GitCredential{
+		Type:     fields.Get(FieldNameGitCredentialType).(string),
 		Username: fields.Get(FieldNameGitCredentialUsername).(string),
-		Password: fields.Get(FieldNameGitCredentialPassword).(string),
 	}
 
-	if gitCredential.Username == "" {
-		return logical.ErrorResponse("%q field value should not be empty", FieldNameGitCredentialUsername), nil
-	}
-	if gitCredential.Password == "" {
-		return logical.ErrorResponse("%q field value should not be empty", FieldNameGitCredentialPassword), nil
+	switch gitCredential.Type {
+	case CredentialTypeBasic:
+		gitCredential.Password = fields.Get(FieldNameGitCredentialPassword).(string)
+
+		if gitCredential.Username == "" {
+			return logical.ErrorResponse("%q field value should not be empty", FieldNameGitCredentialUsername), nil
+		}
+		if gitCredential.Password == "" {
+			return logical.ErrorResponse("%q field value should not be empty", FieldNameGitCredentialPassword), nil
+		}
+
+	case CredentialTypeSSH:
+		gitCredential.SSHPrivateKey = fields.Get(FieldNameGitCredentialSSHPrivateKey).(string)
+		gitCredential.SSHPassphrase = fields.Get(FieldNameGitCredentialSSHPassphrase).(string)
+		gitCredential.SSHKnownHosts = fields.Get(FieldNameGitCredentialSSHKnownHosts).(string)
+		if gitCredential.Username == "" {
+			gitCredential.Username = defaultSSHUsername
+		}
+
+		if gitCredential.SSHPrivateKey == "" {
+			return logical.ErrorResponse("%q field value should not be empty", FieldNameGitCredentialSSHPrivateKey), nil
+		}
+		if gitCredential.SSHKnownHosts == "" {
+			return logical.ErrorResponse("%q field value should not be empty", FieldNameGitCredentialSSHKnownHosts), nil
+		}
+		if _, err := gitCredential.sshAuth(); err != nil {
+			return logical.ErrorResponse("Invalid SSH credentials: %s", err), nil
+		}
+
+	case CredentialTypeToken:
+		gitCredential.Token = fields.Get(FieldNameGitCredentialToken).(string)
+		gitCredential.TokenHeader = fields.Get(FieldNameGitCredentialTokenHeader).(string)
+
+		if gitCredential.Token == "" {
+			return logical.ErrorResponse("%q field value should not be empty", FieldNameGitCredentialToken), nil
+		}
+		if gitCredential.TokenHeader == "" || strings.ContainsAny(gitCredential.TokenHeader, ": \r\n") {
+			return logical.ErrorResponse("%q field value %q is invalid", FieldNameGitCredentialTokenHeader, gitCredential.TokenHeader), nil
+		}
+
+	default:
+		return logical.ErrorResponse("%q field value should be one of %q, %q or %q", FieldNameGitCredentialType, CredentialTypeBasic, CredentialTypeSSH, CredentialTypeToken), nil
 	}
 
 	if err := PutGitCredential(ctx, req.Storage, gitCredential); err != nil {
@@ -87,6 +181,33 @@ func pathConfigureGitCredentialCreateOrUpdate(ctx context.Context, req *logical.
 	return nil, nil
 }
 
+// pathConfigureGitCredentialRead never returns secrets, only whether they are set
+func pathConfigureGitCredentialRead(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	gitCredential, err := GetGitCredential(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get Git credentials: %s", err), nil
+	}
+	if gitCredential == nil {
+		return nil, nil
+	}
+
+	credentialType := gitCredential.Type
+	if credentialType == "" {
+		credentialType = CredentialTypeBasic
+	}
+
+	return &logical.Response{Data: map[string]interface{}{
+		FieldNameGitCredentialType:          credentialType,
+		FieldNameGitCredentialUsername:      gitCredential.Username,
+		FieldNameGitCredentialSSHKnownHosts: gitCredential.SSHKnownHosts,
+		FieldNameGitCredentialTokenHeader:   gitCredential.TokenHeader,
+		"password_set":                      gitCredential.Password != "",
+		"ssh_private_key_set":               gitCredential.SSHPrivateKey != "",
+		"ssh_passphrase_set":                gitCredential.SSHPassphrase != "",
+		"token_set":                         gitCredential.Token != "",
+	}}, nil
+}
+
 func pathConfigureGitCredentialDelete(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
 	if err := DeleteGitCredential(ctx, req.Storage); err != nil {
 		return nil, fmt.Errorf("unable to delete Git credentials configuration: %w", err)
@@ -128,3 +249,15 @@ func PutGitCredential(ctx context.Context, storage logical.Storage, gitCredentia
 func DeleteGitCredential(ctx context.Context, storage logical.Storage) error {
 	return storage.Delete(ctx, StorageKeyConfigurationGitCredential)
 }
+
+const credentialsHelpDesc = `
+Configure Git credentials to perform clone operation.
+
+  type=basic   username and password of HTTP basic authentication
+  type=ssh     ssh_private_key with optional ssh_passphrase, the server key
+               is verified against ssh_known_hosts; username defaults to git
+  type=token   token sent in token_header, as "Bearer <token>" in the
+               default Authorization header
+
+Secrets are stored seal-wrapped and never returned on read.
+`
@@ -2,12 +2,19 @@ package git
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net/http"
 	"testing"
 
+	gitHTTP "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitSSH "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
 )
 
 type PathConfigureGitCredentialsCallbacksSuite struct {
@@ -86,6 +93,7 @@ func (suite *PathConfigureGitCredentialsCallbacksSuite) Test_CreateOrUpdate_Full
 	assert.Nil(err)
 	assert.Equal(
 		&GitCredential{
+			Type:     CredentialTypeBasic,
 			Username: "user",
 			Password: "password",
 		},
@@ -93,6 +101,95 @@ func (suite *PathConfigureGitCredentialsCallbacksSuite) Test_CreateOrUpdate_Full
 	)
 }
 
+func (suite *PathConfigureGitCredentialsCallbacksSuite) Test_CreateOrUpdate_SSH() {
+	assert := assert.New(suite.T())
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(err)
+	pemBlock, err := ssh.MarshalPrivateKey(privateKey, "")
+	assert.Nil(err)
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	assert.Nil(err)
+	knownHosts := "git.example.com " + string(ssh.MarshalAuthorizedKey(sshPublicKey))
+
+	suite.req.Operation = logical.CreateOperation
+	suite.req.Data = map[string]interface{}{
+		FieldNameGitCredentialType:          CredentialTypeSSH,
+		FieldNameGitCredentialSSHPrivateKey: string(pem.EncodeToMemory(pemBlock)),
+	}
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(err)
+	assert.Equal(logical.ErrorResponse("%q field value should not be empty", FieldNameGitCredentialSSHKnownHosts), resp)
+
+	suite.req.Data[FieldNameGitCredentialSSHKnownHosts] = knownHosts
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(err)
+	assert.Nil(resp)
+
+	cfg, err := GetGitCredential(suite.ctx, suite.storage)
+	assert.Nil(err)
+	auth, err := cfg.AuthMethod()
+	assert.Nil(err)
+	publicKeys, ok := auth.(*gitSSH.PublicKeys)
+	assert.True(ok)
+	assert.Equal("git", publicKeys.User)
+	assert.Equal([]string{ssh.KeyAlgoED25519}, publicKeys.HostKeyAlgorithms)
+
+	suite.req.Operation = logical.ReadOperation
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(err)
+	assert.Equal(true, resp.Data["ssh_private_key_set"])
+	assert.Equal(knownHosts, resp.Data[FieldNameGitCredentialSSHKnownHosts])
+	assert.NotContains(resp.Data, FieldNameGitCredentialSSHPrivateKey)
+}
+
+func (suite *PathConfigureGitCredentialsCallbacksSuite) Test_CreateOrUpdate_SSH_InvalidKey() {
+	assert := assert.New(suite.T())
+
+	suite.req.Operation = logical.CreateOperation
+	suite.req.Data = map[string]interface{}{
+		FieldNameGitCredentialType:          CredentialTypeSSH,
+		FieldNameGitCredentialSSHPrivateKey: "not a key",
+		FieldNameGitCredentialSSHKnownHosts: "git.example.com ssh-ed25519 AAAA",
+	}
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(err)
+	assert.True(resp.IsError())
+}
+
+func (suite *PathConfigureGitCredentialsCallbacksSuite) Test_CreateOrUpdate_Token() {
+	assert := assert.New(suite.T())
+
+	suite.req.Operation = logical.CreateOperation
+	suite.req.Data = map[string]interface{}{
+		FieldNameGitCredentialType:  CredentialTypeToken,
+		FieldNameGitCredentialToken: "secret",
+	}
+
+	resp, err := suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(err)
+	assert.Nil(resp)
+
+	cfg, err := GetGitCredential(suite.ctx, suite.storage)
+	assert.Nil(err)
+	auth, err := cfg.AuthMethod()
+	assert.Nil(err)
+	httpAuth, ok := auth.(gitHTTP.AuthMethod)
+	assert.True(ok)
+	httpReq, err := http.NewRequest(http.MethodGet, "https://git.example.com", nil)
+	assert.Nil(err)
+	httpAuth.SetAuth(httpReq)
+	assert.Equal("Bearer secret", httpReq.Header.Get("Authorization"))
+
+	suite.req.Operation = logical.ReadOperation
+	resp, err = suite.backend.HandleRequest(suite.ctx, suite.req)
+	assert.Nil(err)
+	assert.Equal(true, resp.Data["token_set"])
+	assert.NotContains(resp.Data, FieldNameGitCredentialToken)
+}
+
 func (suite *PathConfigureGitCredentialsCallbacksSuite) Test_Delete_NoConfig() {
 	assert := assert.New(suite.T())
 
@@ -11,6 +11,7 @@ import (
 	"github.com/go-git/go-billy/v5/memfs"
 	git "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/storage/memory"
 )
@@ -191,3 +192,37 @@ func IsAncestor(gitRepo *git.Repository, ancestorCommit, descendantCommit string
 
 	return isAncestor, nil
 }
+
+// ChangedFiles returns paths of files added, modified or deleted between trees of the commits
+func ChangedFiles(gitRepo *git.Repository, fromCommit, toCommit string) ([]string, error) {
+	var trees []*object.Tree
+	for _, commit := range []string{fromCommit, toCommit} {
+		commitObj, err := gitRepo.CommitObject(plumbing.NewHash(commit))
+		if err != nil {
+			return nil, fmt.Errorf("unable to get commit %q object: %w", commit, err)
+		}
+
+		tree, err := commitObj.Tree()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get tree of commit %q: %w", commit, err)
+		}
+		trees = append(trees, tree)
+	}
+
+	changes, err := object.DiffTree(trees[0], trees[1])
+	if err != nil {
+		return nil, fmt.Errorf("unable to diff commit %q to %q: %w", fromCommit, toCommit, err)
+	}
+
+	var files []string
+	for _, change := range changes {
+		if change.From.Name != "" {
+			files = append(files, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			files = append(files, change.To.Name)
+		}
+	}
+
+	return files, nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/go-git/go-git/v5"
@@ -91,6 +92,51 @@ func VerifyCommitSignatures(repo *git.Repository, commit string, trustedPGPPubli
 	return verifyObjectSignatures(repo, commit, trustedPGPPublicKeys, requiredNumberOfVerifiedSignatures, logger)
 }
 
+// CommitSigners returns sorted names of trusted keys which verify a signature of the commit
+func CommitSigners(repo *git.Repository, commit string, trustedPGPPublicKeys map[string]string, logger hclog.Logger) ([]string, error) {
+	co, err := repo.CommitObject(plumbing.NewHash(commit))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get commit %q: %w", commit, err)
+	}
+
+	noteSignatures, err := objectSignaturesFromNotes(repo, commit)
+	if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return nil, err
+	}
+
+	var signers []string
+	for name, key := range trustedPGPPublicKeys {
+		if co.PGPSignature != "" {
+			encoded := &plumbing.MemoryObject{}
+			if err := co.EncodeWithoutSignature(encoded); err != nil {
+				return nil, err
+			}
+
+			_, left, err := pgp.VerifyPGPSignatures([]string{co.PGPSignature}, func() (io.Reader, error) { return encoded.Reader() }, []string{key}, 1, logger)
+			if err != nil {
+				return nil, err
+			}
+			if left == 0 {
+				signers = append(signers, name)
+				continue
+			}
+		}
+
+		if len(noteSignatures) > 0 {
+			_, left, err := pgp.VerifyPGPSignatures(noteSignatures, func() (io.Reader, error) { return strings.NewReader(commit), nil }, []string{key}, 1, logger)
+			if err != nil {
+				return nil, err
+			}
+			if left == 0 {
+				signers = append(signers, name)
+			}
+		}
+	}
+
+	sort.Strings(signers)
+	return signers, nil
+}
+
 func verifyObjectSignatures(repo *git.Repository, objectID string, trustedPGPPublicKeys []string, requiredNumberOfVerifiedSignatures int, logger hclog.Logger) error {
 	signatures, err := objectSignaturesFromNotes(repo, objectID)
 	if err != nil {
@@ -18,6 +18,12 @@ const (
 	FieldNameGitBranch                                  = "git_branch_name"
 	FieldNameGitPollPeriod                              = "git_poll_period"
 	FieldNameRequiredNumberOfVerifiedSignaturesOnCommit = "required_number_of_verified_signatures_on_commit"
+	FieldNameApplyMode                                  = "apply_mode"
+
+	// ApplyModeLatest applies only the newest signed commit
+	ApplyModeLatest = "latest"
+	// ApplyModeSequential applies every signed commit of the first-parent history in order
+	ApplyModeSequential = "sequential"
 
 	StorageKeyConfiguration = "git_repository_configuration"
 )
@@ -28,6 +34,7 @@ type Configuration struct {
 	GitBranch                                  string        `structs:"git_branch_name" json:"git_branch_name"`
 	GitPollPeriod                              time.Duration `structs:"git_poll_period" json:"git_poll_period"`
 	RequiredNumberOfVerifiedSignaturesOnCommit int           `structs:"required_number_of_verified_signatures_on_commit" json:"required_number_of_verified_signatures_on_commit"`
+	ApplyMode                                  string        `structs:"apply_mode" json:"apply_mode,omitempty"`
 }
 
 type backend struct {
@@ -71,6 +78,12 @@ func Paths(baseBackend *framework.Backend) []*framework.Path {
 					Default:     0,
 					Description: "Verify that the commit has enough verified signatures",
 				},
+				FieldNameApplyMode: {
+					Type:          framework.TypeString,
+					Default:       ApplyModeLatest,
+					AllowedValues: []interface{}{ApplyModeLatest, ApplyModeSequential},
+					Description:   "latest applies only the newest signed commit, sequential applies every signed commit of the first-parent history in order",
+				},
 			},
 
 			Operations: map[logical.Operation]framework.OperationHandler{
@@ -147,6 +160,18 @@ func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.
 		config.RequiredNumberOfVerifiedSignaturesOnCommit = requiredSignatures.(int)
 	}
 
+	if applyMode, ok := fields.GetOk(FieldNameApplyMode); ok {
+		config.ApplyMode = applyMode.(string)
+	}
+
+	switch config.ApplyMode {
+	case "":
+		config.ApplyMode = ApplyModeLatest
+	case ApplyModeLatest, ApplyModeSequential:
+	default:
+		return logical.ErrorResponse("%q field value should be %q or %q", FieldNameApplyMode, ApplyModeLatest, ApplyModeSequential), nil
+	}
+
 	// Validate GitRepoUrl for CREATE operation
 	if req.Operation == logical.CreateOperation && config.GitRepoUrl == "" {
 		return logical.ErrorResponse("%q field value should not be empty", FieldNameGitRepoUrl), nil
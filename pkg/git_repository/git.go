@@ -8,11 +8,14 @@ import (
 	"time"
 
 	goGit "github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/directives"
 	trdlGit "github.com/trublast/vault-plugin-gitops-terraform/pkg/git"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/pgp"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
 )
 
 type gitCommitHash = string
@@ -21,12 +24,31 @@ type gitCommitHash = string
 type CommitInfo struct {
 	CommitHash string
 	CommitDate time.Time
+	// Directives are Gitops-* trailers of the commit message, covered by the commit signatures
+	Directives []directives.Directive
+	// NoRelevantChanges is true if the commit changes no file relevant to the change filter
+	NoRelevantChanges bool
+}
+
+// HeadCommit is the HEAD of the branch seen by the last search
+type HeadCommit struct {
+	CommitHash string
+	CommitDate time.Time
+	// Signed is true if the commit has the required number of verified signatures
+	Signed bool
 }
 
 type gitService struct {
 	ctx     context.Context
 	storage logical.Storage
 	logger  hclog.Logger
+	onPhase func(phase string)
+	onHead  func(head *HeadCommit)
+	// onRejected is called for every commit skipped for insufficient verified signatures
+	onRejected func(commitHash string)
+	// changeBase is the commit found commits are compared to by isRelevantChange
+	changeBase       gitCommitHash
+	isRelevantChange func(changedFiles []string) bool
 }
 
 func GitService(ctx context.Context, storage logical.Storage, logger hclog.Logger) gitService {
@@ -37,110 +59,363 @@ func GitService(ctx context.Context, storage logical.Storage, logger hclog.Logge
 	}
 }
 
+// WithPhaseCallback returns a copy of the service which reports clone and verify phases to onPhase
+func (g gitService) WithPhaseCallback(onPhase func(phase string)) gitService {
+	g.onPhase = onPhase
+	return g
+}
+
+// WithHeadCallback returns a copy of the service which reports HEAD of the branch to onHead on each search
+func (g gitService) WithHeadCallback(onHead func(head *HeadCommit)) gitService {
+	g.onHead = onHead
+	return g
+}
+
+// WithRejectCallback returns a copy of the service which reports commits skipped for insufficient signatures to onRejected
+func (g gitService) WithRejectCallback(onRejected func(commitHash string)) gitService {
+	g.onRejected = onRejected
+	return g
+}
+
+// WithChangeFilter returns a copy of the service which marks found commits with NoRelevantChanges
+// if isRelevantChange returns false for files changed since baseCommit, or since the previous found commit
+func (g gitService) WithChangeFilter(baseCommit gitCommitHash, isRelevantChange func(changedFiles []string) bool) gitService {
+	g.changeBase = baseCommit
+	g.isRelevantChange = isRelevantChange
+	return g
+}
+
+func (g gitService) reportPhase(phase string) {
+	if g.onPhase != nil {
+		g.onPhase(phase)
+	}
+}
+
 // FindFirstSignedCommitFromHead searches for the first signed commit starting from HEAD
 // and going backwards until lastFinishedCommit.
 // Returns the first commit that has the required number of verified signatures.
 // Validates that commit date is not newer than current time and not older than lastFinishedCommit date.
 func (g gitService) FindFirstSignedCommitFromHead(lastFinishedCommit *CommitInfo) (*CommitInfo, error) {
-	config, err := GetConfig(g.ctx, g.storage, g.logger)
+	search, err := g.prepareSearch(lastFinishedCommit)
+	if err != nil || search == nil {
+		return nil, err
+	}
+
+	return g.findFirstSignedCommit(search)
+}
+
+// findFirstSignedCommit returns the newest qualified commit between HEAD and the boundary of the search
+func (g gitService) findFirstSignedCommit(search *commitSearch) (*CommitInfo, error) {
+	// Iterate from HEAD backwards until we find a signed commit or reach the boundary
+	commitIter, err := search.gitRepo.Log(&goGit.LogOptions{From: search.head.Hash})
 	if err != nil {
+		return nil, fmt.Errorf("unable to create commit iterator: %w", err)
+	}
+	defer commitIter.Close()
+
+	for {
+		c, err := commitIter.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				// Reached the end of history
+				break
+			}
+			return nil, fmt.Errorf("error iterating commits: %w", err)
+		}
+
+		// Stop if we reached the boundary commit
+		if search.isBoundary(c) {
+			g.logger.Debug(fmt.Sprintf("Reached boundary commit %q, stopping search", c.Hash.String()))
+			break
+		}
+
+		if !g.isCommitQualified(search, c) {
+			continue
+		}
+
+		// Found a commit with required signatures and valid date
+		g.logger.Info(fmt.Sprintf("Found signed commit: %q with date %v", c.Hash.String(), c.Committer.When))
+		return &CommitInfo{
+			CommitHash:        c.Hash.String(),
+			CommitDate:        c.Committer.When,
+			Directives:        directives.Parse(c.Message),
+			NoRelevantChanges: !g.hasRelevantChanges(search.gitRepo, g.changeBase, c),
+		}, nil
+	}
+
+	// No signed commit found
+	g.logger.Debug("No signed commit found in the search range")
+	return nil, nil
+}
+
+// FindSignedCommitsSequence walks the first-parent history from HEAD back to lastFinishedCommit
+// and returns all commits which have the required number of verified signatures, the oldest first.
+// Without lastFinishedCommit only the newest signed commit is returned, the whole history is never applied.
+// The same is done if lastFinishedCommit is not in the first-parent history, e.g. it was merged
+// from another branch or the branch was force-pushed
+func (g gitService) FindSignedCommitsSequence(lastFinishedCommit *CommitInfo) ([]*CommitInfo, error) {
+	if lastFinishedCommit == nil {
+		commitInfo, err := g.FindFirstSignedCommitFromHead(nil)
+		if err != nil || commitInfo == nil {
+			return nil, err
+		}
+		return []*CommitInfo{commitInfo}, nil
+	}
+
+	search, err := g.prepareSearch(lastFinishedCommit)
+	if err != nil || search == nil {
 		return nil, err
 	}
 
-	// Determine the boundary commit: use lastFinishedCommit hash
-	boundaryCommit := ""
-	if lastFinishedCommit != nil {
-		boundaryCommit = lastFinishedCommit.CommitHash
+	chain, err := firstParentChain(search.head, lastFinishedCommit.CommitHash)
+	if err != nil {
+		return nil, err
+	}
+	if chain == nil {
+		g.logger.Warn(fmt.Sprintf("Last finished commit %q is not in the first-parent history of HEAD, searching for the newest signed commit only", lastFinishedCommit.CommitHash))
+		commitInfo, err := g.findFirstSignedCommit(search)
+		if err != nil || commitInfo == nil {
+			return nil, err
+		}
+		return []*CommitInfo{commitInfo}, nil
+	}
+
+	var result []*CommitInfo
+	changeBase := g.changeBase
+	for i := len(chain) - 1; i >= 0; i-- {
+		c := chain[i]
+		if !g.isCommitQualified(search, c) {
+			continue
+		}
+
+		g.logger.Info(fmt.Sprintf("Found signed commit: %q with date %v", c.Hash.String(), c.Committer.When))
+		result = append(result, &CommitInfo{
+			CommitHash:        c.Hash.String(),
+			CommitDate:        c.Committer.When,
+			Directives:        directives.Parse(c.Message),
+			NoRelevantChanges: !g.hasRelevantChanges(search.gitRepo, changeBase, c),
+		})
+		// Commits are applied in order, so the next one is compared to this one
+		changeBase = c.Hash.String()
+	}
+
+	if len(result) == 0 {
+		g.logger.Debug("No signed commit found in the search range")
+	}
+	return result, nil
+}
+
+// firstParentChain returns commits of the first-parent history from head down to the boundary commit,
+// the boundary itself is not included. Returns nil if the boundary is not in the first-parent history
+func firstParentChain(head *object.Commit, boundaryCommit gitCommitHash) ([]*object.Commit, error) {
+	chain := []*object.Commit{}
+	for c := head; c.Hash.String() != boundaryCommit; {
+		chain = append(chain, c)
+
+		if c.NumParents() == 0 {
+			return nil, nil
+		}
+
+		var err error
+		if c, err = c.Parent(0); err != nil {
+			return nil, fmt.Errorf("unable to get parent of commit %q: %w", chain[len(chain)-1].Hash.String(), err)
+		}
+	}
+
+	return chain, nil
+}
+
+// VerifyBranchCommit checks that the commit has the required number of verified signatures
+// and belongs to the history of the configured branch. Returns the commit and the branch HEAD
+func (g gitService) VerifyBranchCommit(commitHash string) (*CommitInfo, *CommitInfo, error) {
+	config, err := GetConfig(g.ctx, g.storage, g.logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !plumbing.IsHash(commitHash) {
+		return nil, nil, fmt.Errorf("%q is not a full commit hash", commitHash)
+	}
+
+	g.reportPhase(runs.PhaseClone)
+	gitRepo, headCommit, err := g.cloneGit(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cloning repository: %w", err)
+	}
+
+	isAncestor, err := trdlGit.IsAncestor(gitRepo, commitHash, headCommit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isAncestor {
+		return nil, nil, fmt.Errorf("commit %q is not in the history of branch %q", commitHash, config.GitBranch)
+	}
+
+	g.reportPhase(runs.PhaseVerify)
+	trustedPGPPublicKeys, err := pgp.GetTrustedPGPPublicKeys(g.ctx, g.storage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get trusted public keys: %w", err)
+	}
+
+	if err := trdlGit.VerifyCommitSignatures(gitRepo, commitHash, trustedPGPPublicKeys, config.RequiredNumberOfVerifiedSignaturesOnCommit, g.logger); err != nil {
+		return nil, nil, fmt.Errorf("commit %q signatures: %w", commitHash, err)
+	}
+
+	commit, err := gitRepo.CommitObject(plumbing.NewHash(commitHash))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get commit %q object: %w", commitHash, err)
+	}
+
+	head, err := gitRepo.CommitObject(plumbing.NewHash(headCommit))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get HEAD commit object: %w", err)
+	}
+
+	return &CommitInfo{CommitHash: commitHash, CommitDate: commit.Committer.When},
+		&CommitInfo{CommitHash: headCommit, CommitDate: head.Committer.When},
+		nil
+}
+
+// commitSearch holds everything needed to check commits between HEAD and the boundary
+type commitSearch struct {
+	config               *Configuration
+	gitRepo              *goGit.Repository
+	head                 *object.Commit
+	lastFinishedCommit   *CommitInfo
+	trustedPGPPublicKeys []string
+	// for date validation
+	currentTime time.Time
+}
+
+func (s *commitSearch) isBoundary(c *object.Commit) bool {
+	return s.lastFinishedCommit != nil && c.Hash.String() == s.lastFinishedCommit.CommitHash
+}
+
+// prepareSearch clones the repository and loads trusted keys, returns nil if HEAD is the boundary
+func (g gitService) prepareSearch(lastFinishedCommit *CommitInfo) (*commitSearch, error) {
+	config, err := GetConfig(g.ctx, g.storage, g.logger)
+	if err != nil {
+		return nil, err
 	}
 
 	// Clone git repository and get head commit
+	g.reportPhase(runs.PhaseClone)
 	g.logger.Debug(fmt.Sprintf("Cloning git repo %q branch %q", config.GitRepoUrl, config.GitBranch))
 	gitRepo, headCommit, err := g.cloneGit(config)
 	if err != nil {
 		return nil, fmt.Errorf("cloning repository: %w", err)
 	}
 
+	if err := g.reportHead(config, gitRepo, headCommit); err != nil {
+		return nil, err
+	}
+
 	// If boundary commit is set and equals HEAD, nothing to process
-	if boundaryCommit != "" && boundaryCommit == headCommit {
+	if lastFinishedCommit != nil && lastFinishedCommit.CommitHash == headCommit {
 		g.logger.Debug("Head commit equals boundary commit: no new commits to process")
 		return nil, nil
 	}
 
 	// Get trusted PGP keys
+	g.reportPhase(runs.PhaseVerify)
 	trustedPGPPublicKeys, err := pgp.GetTrustedPGPPublicKeys(g.ctx, g.storage)
 	if err != nil {
 		return nil, fmt.Errorf("unable to get trusted public keys: %w", err)
 	}
 
-	// Get current time for date validation
-	currentTime := time.Now()
-
-	// Iterate from HEAD backwards until we find a signed commit or reach the boundary
 	ref, err := gitRepo.Head()
 	if err != nil {
 		return nil, fmt.Errorf("unable to get HEAD: %w", err)
 	}
 
-	commit, err := gitRepo.CommitObject(ref.Hash())
+	head, err := gitRepo.CommitObject(ref.Hash())
 	if err != nil {
 		return nil, fmt.Errorf("unable to get HEAD commit object: %w", err)
 	}
 
-	commitIter, err := gitRepo.Log(&goGit.LogOptions{From: commit.Hash})
+	return &commitSearch{
+		config:               config,
+		gitRepo:              gitRepo,
+		head:                 head,
+		lastFinishedCommit:   lastFinishedCommit,
+		trustedPGPPublicKeys: trustedPGPPublicKeys,
+		currentTime:          time.Now(),
+	}, nil
+}
+
+// reportHead checks signatures of HEAD and passes it to onHead
+func (g gitService) reportHead(config *Configuration, gitRepo *goGit.Repository, headCommit gitCommitHash) error {
+	if g.onHead == nil {
+		return nil
+	}
+
+	head, err := gitRepo.CommitObject(plumbing.NewHash(headCommit))
 	if err != nil {
-		return nil, fmt.Errorf("unable to create commit iterator: %w", err)
+		return fmt.Errorf("unable to get HEAD commit object: %w", err)
 	}
-	defer commitIter.Close()
 
-	for {
-		c, err := commitIter.Next()
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				// Reached the end of history
-				break
-			}
-			return nil, fmt.Errorf("error iterating commits: %w", err)
-		}
+	trustedPGPPublicKeys, err := pgp.GetTrustedPGPPublicKeys(g.ctx, g.storage)
+	if err != nil {
+		return fmt.Errorf("unable to get trusted public keys: %w", err)
+	}
 
-		commitHash := c.Hash.String()
-		commitDate := c.Committer.When
+	err = trdlGit.VerifyCommitSignatures(gitRepo, headCommit, trustedPGPPublicKeys, config.RequiredNumberOfVerifiedSignaturesOnCommit, g.logger)
+	if err != nil {
+		g.logger.Debug(fmt.Sprintf("HEAD commit %q does not have required signatures: %s", headCommit, err.Error()))
+	}
 
-		// Stop if we reached the boundary commit
-		if boundaryCommit != "" && commitHash == boundaryCommit {
-			g.logger.Debug(fmt.Sprintf("Reached boundary commit %q, stopping search", boundaryCommit))
-			break
-		}
+	g.onHead(&HeadCommit{
+		CommitHash: headCommit,
+		CommitDate: head.Committer.When,
+		Signed:     err == nil,
+	})
+	return nil
+}
 
-		// Verify commit signatures
-		err = trdlGit.VerifyCommitSignatures(gitRepo, commitHash, trustedPGPPublicKeys, config.RequiredNumberOfVerifiedSignaturesOnCommit, g.logger)
-		if err != nil {
-			g.logger.Debug(fmt.Sprintf("Commit %q does not have required signatures: %s", commitHash, err.Error()))
-			continue
-		}
+// hasRelevantChanges returns true without the change filter or the base commit, and if changes can not be found
+func (g gitService) hasRelevantChanges(gitRepo *goGit.Repository, baseCommit gitCommitHash, c *object.Commit) bool {
+	if g.isRelevantChange == nil || baseCommit == "" {
+		return true
+	}
 
-		// Check that commit date is not newer than current date
-		if commitDate.After(currentTime) {
-			g.logger.Debug(fmt.Sprintf("Commit %q has date %v which is in the future, skipping", commitHash, commitDate))
-			continue
-		}
+	changedFiles, err := trdlGit.ChangedFiles(gitRepo, baseCommit, c.Hash.String())
+	if err != nil {
+		g.logger.Warn(fmt.Sprintf("Unable to find files changed by commit %q: %s", c.Hash.String(), err.Error()))
+		return true
+	}
 
-		// Check that commit date is not older than lastFinishedCommit date
-		if lastFinishedCommit != nil && commitDate.Before(lastFinishedCommit.CommitDate) {
-			g.logger.Debug(fmt.Sprintf("Commit %q has date %v which is older than last finished commit date %v, skipping", commitHash, commitDate, lastFinishedCommit.CommitDate))
-			continue
+	return g.isRelevantChange(changedFiles)
+}
+
+// isCommitQualified checks signatures and date of the commit
+func (g gitService) isCommitQualified(search *commitSearch, c *object.Commit) bool {
+	commitHash := c.Hash.String()
+	commitDate := c.Committer.When
+
+	// Verify commit signatures
+	err := trdlGit.VerifyCommitSignatures(search.gitRepo, commitHash, search.trustedPGPPublicKeys, search.config.RequiredNumberOfVerifiedSignaturesOnCommit, g.logger)
+	if err != nil {
+		g.logger.Debug(fmt.Sprintf("Commit %q does not have required signatures: %s", commitHash, err.Error()))
+		var notEnoughErr *trdlGit.NotEnoughVerifiedPGPSignaturesError
+		if g.onRejected != nil && errors.As(err, &notEnoughErr) {
+			g.onRejected(commitHash)
 		}
+		return false
+	}
 
-		// Found a commit with required signatures and valid date
-		g.logger.Info(fmt.Sprintf("Found signed commit: %q with date %v", commitHash, commitDate))
-		return &CommitInfo{
-			CommitHash: commitHash,
-			CommitDate: commitDate,
-		}, nil
+	// Check that commit date is not newer than current date
+	if commitDate.After(search.currentTime) {
+		g.logger.Debug(fmt.Sprintf("Commit %q has date %v which is in the future, skipping", commitHash, commitDate))
+		return false
 	}
 
-	// No signed commit found
-	g.logger.Debug("No signed commit found in the search range")
-	return nil, nil
+	// Check that commit date is not older than lastFinishedCommit date
+	if search.lastFinishedCommit != nil && commitDate.Before(search.lastFinishedCommit.CommitDate) {
+		g.logger.Debug(fmt.Sprintf("Commit %q has date %v which is older than last finished commit date %v, skipping", commitHash, commitDate, search.lastFinishedCommit.CommitDate))
+		return false
+	}
+
+	return true
 }
 
 // cloneGit clones specified repo, checkout specified branch and return head commit of branch
@@ -155,11 +430,8 @@ func (g gitService) cloneGit(config *Configuration) (*goGit.Repository, gitCommi
 		cloneOptions.BranchName = config.GitBranch
 		// cloneOptions.RecurseSubmodules = goGit.DefaultSubmoduleRecursionDepth //
 
-		if gitCredentials != nil && gitCredentials.Username != "" && gitCredentials.Password != "" {
-			cloneOptions.Auth = &http.BasicAuth{
-				Username: gitCredentials.Username,
-				Password: gitCredentials.Password,
-			}
+		if cloneOptions.Auth, err = gitCredentials.AuthMethod(); err != nil {
+			return nil, "", fmt.Errorf("unable to get Git credentials: %w", err)
 		}
 
 		if config.GitCACertificate != "" {
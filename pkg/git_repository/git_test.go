@@ -0,0 +1,81 @@
+package git_repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	goGit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_firstParentChain(t *testing.T) {
+	gitRepo, err := goGit.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+	worktree, err := gitRepo.Worktree()
+	require.NoError(t, err)
+
+	commit := func(message string, parents ...plumbing.Hash) plumbing.Hash {
+		hash, err := worktree.Commit(message, &goGit.CommitOptions{
+			Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+			AllowEmptyCommits: true,
+			Parents:           parents,
+		})
+		require.NoError(t, err)
+		return hash
+	}
+
+	// root <- main <- merge, side branch from root is merged into merge
+	root := commit("root")
+	main := commit("main", root)
+	side := commit("side", root)
+	merge := commit("merge", main, side)
+
+	head, err := gitRepo.CommitObject(merge)
+	require.NoError(t, err)
+
+	tests := []struct {
+		description    string
+		boundaryCommit plumbing.Hash
+		expected       []plumbing.Hash
+	}{
+		{
+			description:    "boundary in first-parent history",
+			boundaryCommit: root,
+			expected:       []plumbing.Hash{merge, main},
+		},
+		{
+			description:    "boundary is HEAD",
+			boundaryCommit: merge,
+			expected:       []plumbing.Hash{},
+		},
+		{
+			description:    "boundary merged from another branch",
+			boundaryCommit: side,
+		},
+		{
+			description:    "boundary not in history",
+			boundaryCommit: plumbing.NewHash("0123456789abcdef0123456789abcdef01234567"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			chain, err := firstParentChain(head, tt.boundaryCommit.String())
+			require.NoError(t, err)
+
+			if tt.expected == nil {
+				require.Nil(t, chain)
+				return
+			}
+			hashes := []plumbing.Hash{}
+			for _, c := range chain {
+				hashes = append(hashes, c.Hash)
+			}
+			require.Equal(t, tt.expected, hashes)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	StorageKeyLease = "run_lease"
+)
+
+// Lease is held by the node which runs terraform, it expires unless the owner heartbeats it
+type Lease struct {
+	Owner       string    `json:"owner"`
+	RunID       string    `json:"run_id"`
+	AcquiredAt  time.Time `json:"acquired_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// IsExpired returns true if the owner stopped heartbeating the lease
+func (l *Lease) IsExpired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+// HeldError is returned when the lease is held by another owner or run
+type HeldError struct {
+	Lease *Lease
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("run lease is held by %q (run %q) until %s", e.Lease.Owner, e.Lease.RunID, e.Lease.ExpiresAt.Format(time.RFC3339))
+}
+
+// LostError is returned when the lease of the run expired or was taken over by another owner
+type LostError struct {
+	RunID string
+}
+
+func (e *LostError) Error() string {
+	return fmt.Sprintf("run lease of run %q is lost", e.RunID)
+}
+
+// Acquire takes the lease for the run, an expired lease of another owner is taken over.
+// Storage has no compare-and-swap: the written lease is read back after settle time, so of owners
+// acquiring the lease at the same time only the last writer gets it
+func Acquire(ctx context.Context, storage logical.Storage, owner, runID string, now time.Time, ttl, settle time.Duration) (*Lease, error) {
+	current, err := Get(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && !current.IsExpired(now) && !current.isHeldBy(owner, runID) {
+		return nil, &HeldError{Lease: current}
+	}
+
+	lease := &Lease{
+		Owner:       owner,
+		RunID:       runID,
+		AcquiredAt:  now,
+		HeartbeatAt: now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	if err := util.PutJSON(ctx, storage, StorageKeyLease, lease); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-time.After(settle):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	stored, err := Get(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, &LostError{RunID: runID}
+	}
+	if !stored.isHeldBy(owner, runID) {
+		return nil, &HeldError{Lease: stored}
+	}
+
+	return lease, nil
+}
+
+// Heartbeat extends the lease, returns *LostError if the lease expired or was taken over.
+// An expired lease is not extended, another owner may be taking it over at the same time
+func Heartbeat(ctx context.Context, storage logical.Storage, lease *Lease, now time.Time, ttl time.Duration) error {
+	if err := Verify(ctx, storage, lease, now); err != nil {
+		return err
+	}
+
+	lease.HeartbeatAt = now
+	lease.ExpiresAt = now.Add(ttl)
+	if err := util.PutJSON(ctx, storage, StorageKeyLease, lease); err != nil {
+		return err
+	}
+
+	return Verify(ctx, storage, lease, now)
+}
+
+// Verify returns *LostError if the lease is not held by the owner of the given one or expired
+func Verify(ctx context.Context, storage logical.Storage, lease *Lease, now time.Time) error {
+	current, err := Get(ctx, storage)
+	if err != nil {
+		return err
+	}
+	if current == nil || !current.isHeldBy(lease.Owner, lease.RunID) || current.IsExpired(now) {
+		return &LostError{RunID: lease.RunID}
+	}
+
+	return nil
+}
+
+// Release deletes the lease if it is still held by the owner of the given one
+func Release(ctx context.Context, storage logical.Storage, lease *Lease) error {
+	current, err := Get(ctx, storage)
+	if err != nil {
+		return err
+	}
+	if current == nil || !current.isHeldBy(lease.Owner, lease.RunID) {
+		return nil
+	}
+	return storage.Delete(ctx, StorageKeyLease)
+}
+
+// Get returns nil if the lease was never acquired or is released
+func Get(ctx context.Context, storage logical.Storage) (*Lease, error) {
+	var lease *Lease
+	if err := util.GetJSON(ctx, storage, StorageKeyLease, &lease); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (l *Lease) isHeldBy(owner, runID string) bool {
+	return l.Owner == owner && l.RunID == runID
+}
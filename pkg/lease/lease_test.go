@@ -0,0 +1,75 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Lease(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	ttl := 5 * time.Minute
+
+	lease, err := Acquire(ctx, storage, "node-a", "run-1", now, ttl, 0)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(ttl), lease.ExpiresAt)
+
+	_, err = Acquire(ctx, storage, "node-b", "run-2", now.Add(time.Minute), ttl, 0)
+	var heldErr *HeldError
+	require.True(t, errors.As(err, &heldErr), "lease is held by another node")
+	require.Equal(t, "node-a", heldErr.Lease.Owner)
+
+	require.NoError(t, Heartbeat(ctx, storage, lease, now.Add(4*time.Minute), ttl))
+	_, err = Acquire(ctx, storage, "node-b", "run-2", now.Add(6*time.Minute), ttl, 0)
+	require.True(t, errors.As(err, &heldErr), "heartbeat extends the lease")
+
+	stolen, err := Acquire(ctx, storage, "node-b", "run-2", now.Add(10*time.Minute), ttl, 0)
+	require.NoError(t, err, "expired lease is taken over")
+	require.Equal(t, "node-b", stolen.Owner)
+
+	var lostErr *LostError
+	require.ErrorAs(t, Heartbeat(ctx, storage, lease, now.Add(10*time.Minute), ttl), &lostErr, "lost lease can not be extended")
+	require.ErrorAs(t, Heartbeat(ctx, storage, stolen, now.Add(20*time.Minute), ttl), &lostErr, "expired lease can not be extended")
+	require.NoError(t, Release(ctx, storage, lease))
+	current, err := Get(ctx, storage)
+	require.NoError(t, err)
+	require.Equal(t, "node-b", current.Owner, "lost lease is not released")
+
+	require.NoError(t, Release(ctx, storage, stolen))
+	current, err = Get(ctx, storage)
+	require.NoError(t, err)
+	require.Nil(t, current)
+}
+
+func Test_Lease_ConcurrentAcquire(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	owners := []string{"node-a", "node-b", "node-c"}
+	errs := make(chan error, len(owners))
+	for _, owner := range owners {
+		go func(owner string) {
+			_, err := Acquire(ctx, storage, owner, "run-"+owner, now, 5*time.Minute, 100*time.Millisecond)
+			errs <- err
+		}(owner)
+	}
+
+	acquired := 0
+	for range owners {
+		err := <-errs
+		if err == nil {
+			acquired++
+			continue
+		}
+		var heldErr *HeldError
+		require.ErrorAs(t, err, &heldErr)
+	}
+	require.Equal(t, 1, acquired, "only one owner gets the lease")
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	MetricRuns            = "gitops_terraform_runs_total"
+	MetricPhaseDuration   = "gitops_terraform_phase_duration_seconds"
+	MetricCommitsRejected = "gitops_terraform_commits_rejected_total"
+)
+
+type runKey struct {
+	workspace string
+	status    string
+}
+
+type phaseKey struct {
+	workspace string
+	phase     string
+}
+
+type summary struct {
+	sum   float64
+	count float64
+}
+
+// Collector keeps counters of the backend in memory, they start from zero when the plugin is loaded
+type Collector struct {
+	mutex           sync.Mutex
+	runs            map[runKey]float64
+	phaseDurations  map[phaseKey]*summary
+	rejectedCommits map[string]float64
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		runs:            map[runKey]float64{},
+		phaseDurations:  map[phaseKey]*summary{},
+		rejectedCommits: map[string]float64{},
+	}
+}
+
+// RunFinished counts the finished run by its final status
+func (c *Collector) RunFinished(workspace, status string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.runs[runKey{workspace: workspace, status: status}]++
+}
+
+// PhaseFinished observes time spent by a run in the phase
+func (c *Collector) PhaseFinished(workspace, phase string, duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := phaseKey{workspace: workspace, phase: phase}
+	s, ok := c.phaseDurations[key]
+	if !ok {
+		s = &summary{}
+		c.phaseDurations[key] = s
+	}
+	s.sum += duration.Seconds()
+	s.count++
+}
+
+// CommitRejected counts the commit skipped for insufficient verified signatures
+func (c *Collector) CommitRejected(workspace string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rejectedCommits[workspace]++
+}
+
+// Families returns the current values of counters
+func (c *Collector) Families() []Family {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	runs := Family{Name: MetricRuns, Type: TypeCounter, Help: "Finished runs by final status."}
+	for key, value := range c.runs {
+		runs.Samples = append(runs.Samples, Sample{
+			Labels: []Label{{"workspace", key.workspace}, {"status", key.status}},
+			Value:  value,
+		})
+	}
+
+	phaseDurations := Family{Name: MetricPhaseDuration, Type: TypeSummary, Help: "Time spent by runs in the phase."}
+	for key, s := range c.phaseDurations {
+		labels := []Label{{"workspace", key.workspace}, {"phase", key.phase}}
+		phaseDurations.Samples = append(phaseDurations.Samples,
+			Sample{Suffix: "_sum", Labels: labels, Value: s.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: s.count},
+		)
+	}
+
+	rejectedCommits := Family{Name: MetricCommitsRejected, Type: TypeCounter, Help: "Commits skipped for insufficient verified signatures."}
+	for workspace, value := range c.rejectedCommits {
+		rejectedCommits.Samples = append(rejectedCommits.Samples, Sample{
+			Labels: []Label{{"workspace", workspace}},
+			Value:  value,
+		})
+	}
+
+	families := []Family{runs, phaseDurations, rejectedCommits}
+	for _, family := range families {
+		sortSamples(family.Samples)
+	}
+	return families
+}
+
+// sortSamples orders samples by label values, then by suffix
+func sortSamples(samples []Sample) {
+	sort.SliceStable(samples, func(i, j int) bool {
+		a, b := samples[i], samples[j]
+		for k := 0; k < len(a.Labels) && k < len(b.Labels); k++ {
+			if a.Labels[k].Value != b.Labels[k].Value {
+				return a.Labels[k].Value < b.Labels[k].Value
+			}
+		}
+		return a.Suffix > b.Suffix
+	})
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ContentType of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+	TypeSummary = "summary"
+)
+
+// Family is a metric with all its samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Sample is a single value of the metric, Suffix is appended to the name, e.g. "_sum" of a summary
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Write writes families in the Prometheus text exposition format, families without samples are skipped
+func Write(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		if len(family.Samples) == 0 {
+			continue
+		}
+
+		bw.WriteString("# HELP " + family.Name + " " + escape(family.Help, false) + "\n")
+		bw.WriteString("# TYPE " + family.Name + " " + family.Type + "\n")
+		for _, sample := range family.Samples {
+			bw.WriteString(family.Name + sample.Suffix)
+			if len(sample.Labels) > 0 {
+				bw.WriteString("{")
+				for i, label := range sample.Labels {
+					if i > 0 {
+						bw.WriteString(",")
+					}
+					bw.WriteString(label.Name + `="` + escape(label.Value, true) + `"`)
+				}
+				bw.WriteString("}")
+			}
+			bw.WriteString(" " + strconv.FormatFloat(sample.Value, 'g', -1, 64) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// escape escapes backslashes and line feeds, and double quotes in label values
+func escape(s string, quote bool) string {
+	replacements := []string{`\`, `\\`, "\n", `\n`}
+	if quote {
+		replacements = append(replacements, `"`, `\"`)
+	}
+	return strings.NewReplacer(replacements...).Replace(s)
+}
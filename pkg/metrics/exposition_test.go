@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Write(t *testing.T) {
+	c := NewCollector()
+	c.RunFinished("", "succeeded")
+	c.RunFinished("", "succeeded")
+	c.RunFinished("prod", "failed")
+	c.PhaseFinished("", "plan", 1500*time.Millisecond)
+	c.PhaseFinished("", "plan", 500*time.Millisecond)
+	c.PhaseFinished("", "apply", 3*time.Second)
+
+	families := append(c.Families(), Family{
+		Name: "gitops_terraform_state_size_bytes",
+		Type: TypeGauge,
+		Help: "Size of the \"terraform\" state.",
+		Samples: []Sample{
+			{Labels: []Label{{"workspace", "with \"quote\"\n"}}, Value: 1024},
+		},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, families))
+	require.Equal(t, `# HELP gitops_terraform_runs_total Finished runs by final status.
+# TYPE gitops_terraform_runs_total counter
+gitops_terraform_runs_total{workspace="",status="succeeded"} 2
+gitops_terraform_runs_total{workspace="prod",status="failed"} 1
+# HELP gitops_terraform_phase_duration_seconds Time spent by runs in the phase.
+# TYPE gitops_terraform_phase_duration_seconds summary
+gitops_terraform_phase_duration_seconds_sum{workspace="",phase="apply"} 3
+gitops_terraform_phase_duration_seconds_count{workspace="",phase="apply"} 1
+gitops_terraform_phase_duration_seconds_sum{workspace="",phase="plan"} 2
+gitops_terraform_phase_duration_seconds_count{workspace="",phase="plan"} 2
+# HELP gitops_terraform_state_size_bytes Size of the "terraform" state.
+# TYPE gitops_terraform_state_size_bytes gauge
+gitops_terraform_state_size_bytes{workspace="with \"quote\"\n"} 1024
+`, buf.String())
+}
@@ -0,0 +1,242 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameName        = "name"
+	FieldNameURL         = "url"
+	FieldNameFormat      = "format"
+	FieldNameEvents      = "events"
+	FieldNameMaxAttempts = "max_attempts"
+	FieldNameTimeout     = "timeout"
+
+	defaultMaxAttempts = 3
+	defaultTimeout     = 10 * time.Second
+)
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^configure/notifications/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathChannelsList,
+					Summary:  "List notification channels.",
+				},
+			},
+			HelpSynopsis:    notificationsHelpSyn,
+			HelpDescription: notificationsHelpDesc,
+		},
+		{
+			Pattern: "^configure/notifications/" + framework.GenericNameRegex(FieldNameName) + "$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameName: {
+					Type:        framework.TypeString,
+					Description: "Channel name.",
+				},
+				FieldNameURL: {
+					Type:        framework.TypeString,
+					Description: "URL the notifications are POSTed to. Required for create.",
+				},
+				FieldNameFormat: {
+					Type:        framework.TypeString,
+					Default:     FormatJSON,
+					Description: "Payload format: json for generic webhooks or slack for Slack and Mattermost incoming webhooks.",
+				},
+				FieldNameEvents: {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Events to send: failed, succeeded, quarantined, drift, token_expiring, unsigned_head. Empty means all.",
+				},
+				FieldNameMaxAttempts: {
+					Type:        framework.TypeInt,
+					Default:     defaultMaxAttempts,
+					Description: "Number of delivery attempts, the delay between attempts doubles starting from one second.",
+				},
+				FieldNameTimeout: {
+					Type:        framework.TypeDurationSecond,
+					Default:     int(defaultTimeout.Seconds()),
+					Description: "Timeout of a delivery attempt.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathChannelWrite,
+					Summary:  "Create the notification channel.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathChannelWrite,
+					Summary:  "Update the notification channel.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathChannelRead,
+					Summary:  "Read the notification channel and its last delivery.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathChannelDelete,
+					Summary:  "Delete the notification channel.",
+				},
+			},
+			ExistenceCheck:  b.pathChannelExistenceCheck,
+			HelpSynopsis:    notificationsHelpSyn,
+			HelpDescription: notificationsHelpDesc,
+		},
+	}
+}
+
+// pathChannelExistenceCheck verifies if the channel exists.
+func (b *backend) pathChannelExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	channel, err := GetChannel(ctx, req.Storage, fields.Get(FieldNameName).(string))
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return channel != nil, nil
+}
+
+func (b *backend) pathChannelsList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	names, err := ListChannels(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to list notification channels: %s", err), nil
+	}
+
+	return logical.ListResponse(names), nil
+}
+
+func (b *backend) pathChannelWrite(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	name := fields.Get(FieldNameName).(string)
+
+	channel, err := GetChannel(ctx, req.Storage, name)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get notification channel: %s", err), nil
+	}
+	if channel == nil {
+		channel = &Channel{
+			Name:        name,
+			Format:      FormatJSON,
+			MaxAttempts: defaultMaxAttempts,
+			Timeout:     defaultTimeout,
+		}
+	}
+
+	if channelURL, ok := fields.GetOk(FieldNameURL); ok {
+		channel.URL = channelURL.(string)
+	}
+	if format, ok := fields.GetOk(FieldNameFormat); ok {
+		channel.Format = format.(string)
+	}
+	if events, ok := fields.GetOk(FieldNameEvents); ok {
+		channel.Events = events.([]string)
+	}
+	if maxAttempts, ok := fields.GetOk(FieldNameMaxAttempts); ok {
+		channel.MaxAttempts = maxAttempts.(int)
+	}
+	if timeout, ok := fields.GetOk(FieldNameTimeout); ok {
+		channel.Timeout = time.Duration(timeout.(int)) * time.Second
+	}
+
+	parsedURL, err := url.Parse(channel.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return logical.ErrorResponse("%q field value should be http or https URL", FieldNameURL), nil
+	}
+	if channel.Format != FormatJSON && channel.Format != FormatSlack {
+		return logical.ErrorResponse("%q field value should be %q or %q", FieldNameFormat, FormatJSON, FormatSlack), nil
+	}
+	for _, event := range channel.Events {
+		if !isKnownEvent(event) {
+			return logical.ErrorResponse("%q field has unknown event %q", FieldNameEvents, event), nil
+		}
+	}
+	if channel.MaxAttempts < 1 {
+		return logical.ErrorResponse("%q field value should be positive", FieldNameMaxAttempts), nil
+	}
+	if channel.Timeout <= 0 {
+		return logical.ErrorResponse("%q field value should be positive", FieldNameTimeout), nil
+	}
+
+	if err := PutChannel(ctx, req.Storage, channel); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Info("Notification channel stored", "name", name)
+
+	return nil, nil
+}
+
+func (b *backend) pathChannelRead(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	name := fields.Get(FieldNameName).(string)
+
+	channel, err := GetChannel(ctx, req.Storage, name)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get notification channel: %s", err), nil
+	}
+	if channel == nil {
+		return nil, nil
+	}
+
+	delivery, err := GetLastDelivery(ctx, req.Storage, name)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get last delivery: %s", err), nil
+	}
+
+	data := map[string]interface{}{
+		FieldNameName:        channel.Name,
+		FieldNameURL:         channel.URL,
+		FieldNameFormat:      channel.Format,
+		FieldNameEvents:      channel.Events,
+		FieldNameMaxAttempts: channel.MaxAttempts,
+		FieldNameTimeout:     channel.Timeout.Seconds(),
+	}
+	if delivery != nil {
+		data["last_delivery"] = delivery
+	}
+
+	return &logical.Response{Data: data}, nil
+}
+
+func (b *backend) pathChannelDelete(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	name := fields.Get(FieldNameName).(string)
+	if err := DeleteChannel(ctx, req.Storage, name); err != nil {
+		return logical.ErrorResponse("Unable to delete notification channel: %s", err), nil
+	}
+
+	b.Logger().Info("Notification channel deleted", "name", name)
+
+	return nil, nil
+}
+
+const (
+	notificationsHelpSyn = `
+Outbound notifications about runs.
+`
+	notificationsHelpDesc = `
+Every channel receives events it is subscribed to as an HTTP POST: a JSON
+document with format=json, or a {"text": "..."} message accepted by Slack and
+Mattermost incoming webhooks with format=slack.
+
+Events are failed, succeeded, quarantined, drift, token_expiring and
+unsigned_head. Failed deliveries are retried up to max_attempts times with
+doubling delay, the result of the last delivery is shown on read.
+`
+)
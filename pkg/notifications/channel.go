@@ -0,0 +1,113 @@
+package notifications
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	StorageKeyPrefixChannel  = "notification_channels/"
+	StorageKeyPrefixDelivery = "notification_deliveries/"
+)
+
+// Payload formats
+const (
+	FormatJSON  = "json"
+	FormatSlack = "slack"
+)
+
+// Channel is a configured receiver of notifications
+type Channel struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Format string `json:"format"`
+	// Events the channel is subscribed to, empty means all
+	Events      []string      `json:"events"`
+	MaxAttempts int           `json:"max_attempts"`
+	Timeout     time.Duration `json:"timeout"`
+}
+
+// Wants returns true if the channel is subscribed to the event
+func (c *Channel) Wants(eventType string) bool {
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, event := range c.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is the result of sending an event to the channel
+type Delivery struct {
+	Event     string    `json:"event"`
+	RunID     string    `json:"run_id,omitempty"`
+	Delivered bool      `json:"delivered"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+func PutChannel(ctx context.Context, storage logical.Storage, channel *Channel) error {
+	return util.PutJSON(ctx, storage, StorageKeyPrefixChannel+channel.Name, channel)
+}
+
+// GetChannel returns nil if the channel does not exist
+func GetChannel(ctx context.Context, storage logical.Storage, name string) (*Channel, error) {
+	var channel *Channel
+	if err := util.GetJSON(ctx, storage, StorageKeyPrefixChannel+name, &channel); err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// DeleteChannel deletes the channel with its last delivery
+func DeleteChannel(ctx context.Context, storage logical.Storage, name string) error {
+	if err := storage.Delete(ctx, StorageKeyPrefixDelivery+name); err != nil {
+		return err
+	}
+	return storage.Delete(ctx, StorageKeyPrefixChannel+name)
+}
+
+func ListChannels(ctx context.Context, storage logical.Storage) ([]string, error) {
+	return storage.List(ctx, StorageKeyPrefixChannel)
+}
+
+// ListSubscribed returns channels subscribed to the event
+func ListSubscribed(ctx context.Context, storage logical.Storage, eventType string) ([]*Channel, error) {
+	names, err := ListChannels(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []*Channel
+	for _, name := range names {
+		channel, err := GetChannel(ctx, storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if channel != nil && channel.Wants(eventType) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+func PutLastDelivery(ctx context.Context, storage logical.Storage, name string, delivery *Delivery) error {
+	return util.PutJSON(ctx, storage, StorageKeyPrefixDelivery+name, delivery)
+}
+
+// GetLastDelivery returns nil if nothing was sent to the channel
+func GetLastDelivery(ctx context.Context, storage logical.Storage, name string) (*Delivery, error) {
+	var delivery *Delivery
+	if err := util.GetJSON(ctx, storage, StorageKeyPrefixDelivery+name, &delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
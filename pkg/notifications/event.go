@@ -0,0 +1,150 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+// Event types
+const (
+	EventFailed        = "failed"
+	EventSucceeded     = "succeeded"
+	EventQuarantined   = "quarantined"
+	EventDrift         = "drift"
+	EventTokenExpiring = "token_expiring"
+	EventUnsignedHead  = "unsigned_head"
+)
+
+var knownEvents = []string{EventFailed, EventSucceeded, EventQuarantined, EventDrift, EventTokenExpiring, EventUnsignedHead}
+
+func isKnownEvent(event string) bool {
+	for _, known := range knownEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
+
+// for testability
+var initialBackoff = time.Second
+
+// Event is sent to subscribed channels
+type Event struct {
+	Type         string            `json:"event"`
+	Workspace    string            `json:"workspace"`
+	RunID        string            `json:"run_id,omitempty"`
+	CommitHash   string            `json:"commit_hash,omitempty"`
+	CommitAuthor string            `json:"commit_author,omitempty"`
+	Signers      []string          `json:"signers,omitempty"`
+	PlanSummary  *runs.PlanSummary `json:"plan_summary,omitempty"`
+	Message      string            `json:"message"`
+	Time         time.Time         `json:"time"`
+}
+
+// slackPayload is accepted by Slack and Mattermost incoming webhooks
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// Text returns the human-readable description of the event
+func (e *Event) Text() string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "gitops_terraform: %s", e.Type)
+	if e.Workspace != "" {
+		fmt.Fprintf(&text, " in workspace %q", e.Workspace)
+	}
+	if e.CommitHash != "" {
+		fmt.Fprintf(&text, ", commit %s", e.CommitHash)
+	}
+	if e.CommitAuthor != "" {
+		fmt.Fprintf(&text, " by %s", e.CommitAuthor)
+	}
+	if len(e.Signers) > 0 {
+		fmt.Fprintf(&text, ", signed by %s", strings.Join(e.Signers, ", "))
+	}
+	if e.Message != "" {
+		text.WriteString("\n" + e.Message)
+	}
+	if e.PlanSummary != nil {
+		text.WriteString("\n" + e.PlanSummary.String())
+	}
+	return text.String()
+}
+
+func (e *Event) payload(format string) ([]byte, error) {
+	if format == FormatSlack {
+		return json.Marshal(slackPayload{Text: e.Text()})
+	}
+	return json.Marshal(e)
+}
+
+// Deliver sends the event to the channel, retrying failed attempts with doubling delay
+func Deliver(ctx context.Context, channel *Channel, event *Event) *Delivery {
+	delivery := &Delivery{Event: event.Type, RunID: event.RunID}
+
+	body, err := event.payload(channel.Format)
+	if err != nil {
+		delivery.Error = fmt.Sprintf("unable to marshal payload: %s", err)
+		delivery.SentAt = time.Now()
+		return delivery
+	}
+
+	backoff := initialBackoff
+	for delivery.Attempts < channel.MaxAttempts {
+		if delivery.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				delivery.Error = ctx.Err().Error()
+				delivery.SentAt = time.Now()
+				return delivery
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		delivery.Attempts++
+		err = send(ctx, channel, body)
+		if err == nil {
+			delivery.Delivered = true
+			delivery.Error = ""
+			break
+		}
+		delivery.Error = err.Error()
+	}
+
+	delivery.SentAt = time.Now()
+	return delivery
+}
+
+func send(ctx context.Context, channel *Channel, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, channel.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, channel.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("unexpected response status %d: %s", response.StatusCode, bytes.TrimSpace(message))
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+func Test_Deliver(t *testing.T) {
+	initialBackoff = time.Millisecond
+
+	event := &Event{
+		Type:         EventFailed,
+		Workspace:    "prod",
+		RunID:        "run",
+		CommitHash:   "a",
+		CommitAuthor: "Alice <alice@example.com>",
+		Signers:      []string{"alice", "bob"},
+		PlanSummary:  runs.NewPlanSummary([]runs.ResourceChange{{Address: "vault_mount.kv", Type: "vault_mount", Actions: []string{"delete"}}}),
+		Message:      "terraform apply failed",
+	}
+
+	tests := []struct {
+		description       string
+		format            string
+		failures          int
+		maxAttempts       int
+		expectedDelivered bool
+		expectedAttempts  int
+	}{
+		{
+			description:       "json delivered after retries",
+			format:            FormatJSON,
+			failures:          2,
+			maxAttempts:       3,
+			expectedDelivered: true,
+			expectedAttempts:  3,
+		},
+		{
+			description:       "slack delivered at once",
+			format:            FormatSlack,
+			maxAttempts:       3,
+			expectedDelivered: true,
+			expectedAttempts:  1,
+		},
+		{
+			description:      "attempts exhausted",
+			format:           FormatJSON,
+			failures:         5,
+			maxAttempts:      2,
+			expectedAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= tt.failures {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				var payload map[string]interface{}
+				require.NoError(t, json.Unmarshal(body, &payload))
+
+				if tt.format == FormatSlack {
+					require.Equal(t, event.Text(), payload["text"])
+				} else {
+					require.Equal(t, EventFailed, payload["event"])
+					require.Equal(t, "a", payload["commit_hash"])
+					require.Equal(t, []interface{}{"alice", "bob"}, payload["signers"])
+					require.NotNil(t, payload["plan_summary"])
+				}
+			}))
+			defer server.Close()
+
+			channel := &Channel{Name: "ops", URL: server.URL, Format: tt.format, MaxAttempts: tt.maxAttempts, Timeout: time.Second}
+			delivery := Deliver(context.Background(), channel, event)
+
+			require.Equal(t, tt.expectedDelivered, delivery.Delivered, delivery.Error)
+			require.Equal(t, tt.expectedAttempts, delivery.Attempts)
+			require.Equal(t, !tt.expectedDelivered, delivery.Error != "")
+		})
+	}
+}
+
+func Test_Channel_Wants(t *testing.T) {
+	require.True(t, (&Channel{}).Wants(EventDrift), "empty events means all")
+	require.True(t, (&Channel{Events: []string{EventFailed, EventDrift}}).Wants(EventDrift))
+	require.False(t, (&Channel{Events: []string{EventFailed}}).Wants(EventSucceeded))
+}
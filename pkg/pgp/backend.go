@@ -110,9 +110,9 @@ func pathConfigureTrustedPGPPublicKeyCreateOrUpdate(ctx context.Context, req *lo
 }
 
 func pathConfigureTrustedPGPPublicKeyList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
-	list, err := req.Storage.List(ctx, storageKeyPrefixTrustedPGPPublicKey)
+	list, err := req.Storage.List(ctx, StorageKeyPrefixTrustedPGPPublicKey)
 	if err != nil {
-		return nil, fmt.Errorf("unable to list %q in storage: %w", storageKeyPrefixTrustedPGPPublicKey, err)
+		return nil, fmt.Errorf("unable to list %q in storage: %w", StorageKeyPrefixTrustedPGPPublicKey, err)
 	}
 
 	return logical.ListResponse(list), nil
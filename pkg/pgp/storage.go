@@ -7,11 +7,11 @@ import (
 )
 
 const (
-	storageKeyPrefixTrustedPGPPublicKey = "trusted_pgp_public_key/"
+	StorageKeyPrefixTrustedPGPPublicKey = "trusted_pgp_public_key/"
 )
 
 func GetTrustedPGPPublicKeys(ctx context.Context, storage logical.Storage) ([]string, error) {
-	list, err := storage.List(ctx, storageKeyPrefixTrustedPGPPublicKey)
+	list, err := storage.List(ctx, StorageKeyPrefixTrustedPGPPublicKey)
 	if err != nil {
 		return nil, err
 	}
@@ -30,6 +30,27 @@ func GetTrustedPGPPublicKeys(ctx context.Context, storage logical.Storage) ([]st
 	return trustedPGPPublicKeys, nil
 }
 
+// GetTrustedPGPPublicKeysByName returns trusted keys by their names
+func GetTrustedPGPPublicKeysByName(ctx context.Context, storage logical.Storage) (map[string]string, error) {
+	list, err := storage.List(ctx, StorageKeyPrefixTrustedPGPPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedPGPPublicKeys := make(map[string]string, len(list))
+	for _, name := range list {
+		e, err := storage.Get(ctx, trustedPGPPublicKeyStorageKey(name))
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			trustedPGPPublicKeys[name] = string(e.Value)
+		}
+	}
+
+	return trustedPGPPublicKeys, nil
+}
+
 func trustedPGPPublicKeyStorageKey(name string) string {
-	return storageKeyPrefixTrustedPGPPublicKey + name
+	return StorageKeyPrefixTrustedPGPPublicKey + name
 }
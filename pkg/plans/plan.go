@@ -0,0 +1,115 @@
+package plans
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+// Plan statuses
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusApplied  = "applied"
+	StatusExpired  = "expired"
+)
+
+const (
+	StorageKeyPrefixPlan = "plans/"
+)
+
+// Plan is the stored terraform plan of the commit waiting for approval
+type Plan struct {
+	CommitHash string    `json:"commit_hash"`
+	CommitDate time.Time `json:"commit_date"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+	// StateChecksum is the checksum of terraform state the plan was created against
+	StateChecksum string                `json:"state_checksum"`
+	Changes       []runs.ResourceChange `json:"changes"`
+	// Binary is the plan file, it is removed when the plan is applied or expired
+	Binary []byte `json:"binary,omitempty"`
+	// Rendered is `terraform show -json` output of the plan file
+	Rendered   json.RawMessage `json:"rendered,omitempty"`
+	ApprovedBy string          `json:"approved_by,omitempty"`
+	ApprovedAt time.Time       `json:"approved_at"`
+	AppliedAt  time.Time       `json:"applied_at"`
+}
+
+// IsStale returns true if terraform state was changed since the plan was created
+func (p *Plan) IsStale(stateChecksum string) bool {
+	return p.StateChecksum != stateChecksum
+}
+
+// IsActive returns true if the plan still can be applied
+func (p *Plan) IsActive() bool {
+	return p.Status == StatusPending || p.Status == StatusApproved
+}
+
+// Expire marks the plan as not applicable anymore and drops the plan file
+func (p *Plan) Expire() {
+	p.Status = StatusExpired
+	p.Binary = nil
+}
+
+func PutPlan(ctx context.Context, storage logical.Storage, plan *Plan) error {
+	return util.PutJSON(ctx, storage, planStorageKey(plan.CommitHash), plan)
+}
+
+// GetPlan returns plan of the commit or nil if it does not exist
+func GetPlan(ctx context.Context, storage logical.Storage, commitHash string) (*Plan, error) {
+	var plan *Plan
+	if err := util.GetJSON(ctx, storage, planStorageKey(commitHash), &plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func DeletePlan(ctx context.Context, storage logical.Storage, commitHash string) error {
+	return storage.Delete(ctx, planStorageKey(commitHash))
+}
+
+// ListPlans returns commit hashes of stored plans
+func ListPlans(ctx context.Context, storage logical.Storage) ([]string, error) {
+	return storage.List(ctx, StorageKeyPrefixPlan)
+}
+
+// ExpireActivePlans expires active plans of all commits except the given one,
+// a new plan supersedes them
+func ExpireActivePlans(ctx context.Context, storage logical.Storage, exceptCommitHash string) error {
+	commitHashes, err := ListPlans(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	for _, commitHash := range commitHashes {
+		if commitHash == exceptCommitHash {
+			continue
+		}
+
+		plan, err := GetPlan(ctx, storage, commitHash)
+		if err != nil {
+			return fmt.Errorf("unable to get plan of commit %q: %w", commitHash, err)
+		}
+		if plan == nil || !plan.IsActive() {
+			continue
+		}
+
+		plan.Expire()
+		if err := PutPlan(ctx, storage, plan); err != nil {
+			return fmt.Errorf("unable to store plan of commit %q: %w", commitHash, err)
+		}
+	}
+
+	return nil
+}
+
+func planStorageKey(commitHash string) string {
+	return StorageKeyPrefixPlan + commitHash
+}
@@ -0,0 +1,52 @@
+package plans
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_IsStale(t *testing.T) {
+	plan := &Plan{StateChecksum: "a"}
+
+	require.False(t, plan.IsStale("a"))
+	require.True(t, plan.IsStale("b"))
+	require.True(t, plan.IsStale(""), "state removed since the plan was created")
+}
+
+func Test_ExpireActivePlans(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	for _, plan := range []*Plan{
+		{CommitHash: "a", Status: StatusPending, Binary: []byte("plan")},
+		{CommitHash: "b", Status: StatusApproved, Binary: []byte("plan")},
+		{CommitHash: "c", Status: StatusApplied},
+		{CommitHash: "d", Status: StatusPending, Binary: []byte("plan")},
+	} {
+		require.NoError(t, PutPlan(ctx, storage, plan))
+	}
+
+	require.NoError(t, ExpireActivePlans(ctx, storage, "d"))
+
+	expected := map[string]string{
+		"a": StatusExpired,
+		"b": StatusExpired,
+		"c": StatusApplied,
+		"d": StatusPending,
+	}
+	for commitHash, status := range expected {
+		plan, err := GetPlan(ctx, storage, commitHash)
+		require.NoError(t, err)
+		require.Equal(t, status, plan.Status, commitHash)
+		if status == StatusExpired {
+			require.Nil(t, plan.Binary, "plan file of expired plan is dropped")
+		}
+	}
+
+	plan, err := GetPlan(ctx, storage, "unknown")
+	require.NoError(t, err)
+	require.Nil(t, plan)
+}
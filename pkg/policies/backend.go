@@ -0,0 +1,164 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameName   = "name"
+	FieldNamePolicy = "policy"
+)
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^policies/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathPoliciesList,
+					Summary:  "List Rego policies.",
+				},
+			},
+			HelpSynopsis:    policiesHelpSyn,
+			HelpDescription: policiesHelpDesc,
+		},
+		{
+			Pattern: "^policies/" + framework.GenericNameRegex(FieldNameName) + "$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameName: {
+					Type:        framework.TypeString,
+					Description: "Policy name.",
+				},
+				FieldNamePolicy: {
+					Type:        framework.TypeString,
+					Description: "Rego module with deny and warn rules in package gitops. Required for create.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathPolicyWrite,
+					Summary:  "Create the Rego policy.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathPolicyWrite,
+					Summary:  "Update the Rego policy.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathPolicyRead,
+					Summary:  "Read the Rego policy.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathPolicyDelete,
+					Summary:  "Delete the Rego policy.",
+				},
+			},
+			ExistenceCheck:  b.pathPolicyExistenceCheck,
+			HelpSynopsis:    policiesHelpSyn,
+			HelpDescription: policiesHelpDesc,
+		},
+	}
+}
+
+// pathPolicyExistenceCheck verifies if the policy exists.
+func (b *backend) pathPolicyExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	policy, err := GetPolicy(ctx, req.Storage, fields.Get(FieldNameName).(string))
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return policy != nil, nil
+}
+
+func (b *backend) pathPoliciesList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	names, err := ListPolicies(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to list policies: %s", err), nil
+	}
+
+	return logical.ListResponse(names), nil
+}
+
+func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	name := fields.Get(FieldNameName).(string)
+	source := fields.Get(FieldNamePolicy).(string)
+	if source == "" {
+		return logical.ErrorResponse("%q field value should not be empty", FieldNamePolicy), nil
+	}
+
+	// Policy must compile together with the others, otherwise every plan would fail
+	modules, err := loadModules(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get policies: %s", err), nil
+	}
+	modules[name] = source
+	if _, err := prepare(ctx, modules); err != nil {
+		return logical.ErrorResponse("Invalid policy: %s", err), nil
+	}
+
+	if err := PutPolicy(ctx, req.Storage, &Policy{Name: name, Source: source}); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Info("Rego policy stored", "name", name)
+
+	return nil, nil
+}
+
+func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	policy, err := GetPolicy(ctx, req.Storage, fields.Get(FieldNameName).(string))
+	if err != nil {
+		return logical.ErrorResponse("Unable to get policy: %s", err), nil
+	}
+	if policy == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{Data: map[string]interface{}{
+		FieldNameName:   policy.Name,
+		FieldNamePolicy: policy.Source,
+	}}, nil
+}
+
+func (b *backend) pathPolicyDelete(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	name := fields.Get(FieldNameName).(string)
+	if err := DeletePolicy(ctx, req.Storage, name); err != nil {
+		return logical.ErrorResponse("Unable to delete policy: %s", err), nil
+	}
+
+	b.Logger().Info("Rego policy deleted", "name", name)
+
+	return nil, nil
+}
+
+const (
+	policiesHelpSyn = `
+Rego policies evaluated against every plan.
+`
+	policiesHelpDesc = `
+Policies are Rego modules of package gitops evaluated in-process before apply.
+The input document has the plan in "terraform show -json" format as
+input.plan and the commit as input.commit (hash, date, author, message).
+
+Every message of a "deny" rule blocks the apply and fails the run, messages of
+a "warn" rule are only recorded in the run. A policy must compile together
+with the already stored ones.
+`
+)
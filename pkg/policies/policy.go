@@ -0,0 +1,214 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/open-policy-agent/opa/v1/types"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	StorageKeyPrefixPolicy = "policies/"
+
+	// query returns the document of the package with deny and warn rules
+	query = "data.gitops"
+)
+
+// Policy is the stored Rego module
+type Policy struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// Result of the evaluation of all policies
+type Result struct {
+	Deny []string `json:"deny,omitempty"`
+	Warn []string `json:"warn,omitempty"`
+}
+
+// DeniedError is returned when a deny rule of a policy matches the plan
+type DeniedError struct {
+	Denials []string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("plan denied by policy: %s", strings.Join(e.Denials, "; "))
+}
+
+func PutPolicy(ctx context.Context, storage logical.Storage, policy *Policy) error {
+	return util.PutJSON(ctx, storage, policyStorageKey(policy.Name), policy)
+}
+
+// GetPolicy returns nil if the policy does not exist
+func GetPolicy(ctx context.Context, storage logical.Storage, name string) (*Policy, error) {
+	var policy *Policy
+	if err := util.GetJSON(ctx, storage, policyStorageKey(name), &policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+func DeletePolicy(ctx context.Context, storage logical.Storage, name string) error {
+	return storage.Delete(ctx, policyStorageKey(name))
+}
+
+func ListPolicies(ctx context.Context, storage logical.Storage) ([]string, error) {
+	return storage.List(ctx, StorageKeyPrefixPolicy)
+}
+
+// Evaluator evaluates all stored policies
+type Evaluator struct {
+	query rego.PreparedEvalQuery
+}
+
+// NewEvaluator compiles stored policies, returns nil if there are no policies
+func NewEvaluator(ctx context.Context, storage logical.Storage) (*Evaluator, error) {
+	modules, err := loadModules(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	if len(modules) == 0 {
+		return nil, nil
+	}
+
+	preparedQuery, err := prepare(ctx, modules)
+	if err != nil {
+		return nil, fmt.Errorf("compiling policies: %w", err)
+	}
+
+	return &Evaluator{query: preparedQuery}, nil
+}
+
+// Evaluate returns deny and warn messages of policies for the input
+func (e *Evaluator) Evaluate(ctx context.Context, input map[string]interface{}) (*Result, error) {
+	resultSet, err := e.query.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return nil, fmt.Errorf("evaluating policies: %w", err)
+	}
+
+	// Undefined document: the policies have no rules for the input
+	if len(resultSet) == 0 || len(resultSet[0].Expressions) == 0 {
+		return &Result{}, nil
+	}
+
+	return resultFromValue(resultSet[0].Expressions[0].Value)
+}
+
+// resultFromValue reads deny and warn rules from the package document
+func resultFromValue(value interface{}) (*Result, error) {
+	document, _ := value.(map[string]interface{})
+
+	deny, err := ruleMessages("deny", document["deny"])
+	if err != nil {
+		return nil, err
+	}
+	warn, err := ruleMessages("warn", document["warn"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{Deny: deny, Warn: warn}, nil
+}
+
+// ruleMessages returns sorted messages of the rule, sets are evaluated to arrays.
+// A boolean rule is matched when true, it has a single generic message
+func ruleMessages(rule string, value interface{}) ([]string, error) {
+	var messages []string
+	switch v := value.(type) {
+	case nil:
+	case []interface{}:
+		for _, item := range v {
+			if message, ok := item.(string); ok {
+				messages = append(messages, message)
+			} else {
+				messages = append(messages, fmt.Sprint(item))
+			}
+		}
+	case string:
+		messages = append(messages, v)
+	case bool:
+		if v {
+			messages = append(messages, fmt.Sprintf("%s rule matched", rule))
+		}
+	default:
+		return nil, unsupportedRuleError(rule, fmt.Sprintf("%T", value))
+	}
+
+	sort.Strings(messages)
+	return messages, nil
+}
+
+func loadModules(ctx context.Context, storage logical.Storage) (map[string]string, error) {
+	names, err := ListPolicies(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := make(map[string]string, len(names))
+	for _, name := range names {
+		policy, err := GetPolicy(ctx, storage, name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get policy %q: %w", name, err)
+		}
+		if policy != nil {
+			modules[policy.Name] = policy.Source
+		}
+	}
+
+	return modules, nil
+}
+
+// prepare compiles the modules and checks that deny and warn rules are evaluated to messages
+func prepare(ctx context.Context, modules map[string]string) (rego.PreparedEvalQuery, error) {
+	compiler := ast.NewCompiler()
+	options := []func(r *rego.Rego){rego.Query(query), rego.Compiler(compiler)}
+	for name, source := range modules {
+		options = append(options, rego.Module(name+".rego", source))
+	}
+
+	preparedQuery, err := rego.New(options...).PrepareForEval(ctx)
+	if err != nil {
+		return rego.PreparedEvalQuery{}, err
+	}
+
+	for _, rule := range []string{"deny", "warn"} {
+		ruleType := compiler.TypeEnv.Get(ast.MustParseRef(query + "." + rule))
+		if ruleType != nil && !isMessagesType(ruleType) {
+			return rego.PreparedEvalQuery{}, unsupportedRuleError(rule, types.Sprint(ruleType))
+		}
+	}
+
+	return preparedQuery, nil
+}
+
+// isMessagesType reports whether the rule type can be evaluated to messages: a set or an array, a string
+// or a boolean. Types unknown before the evaluation, e.g. of input values, are checked by the evaluation
+func isMessagesType(ruleType types.Type) bool {
+	switch t := ruleType.(type) {
+	case *types.Set, *types.Array, types.String, types.Boolean:
+		return true
+	case types.Any:
+		for _, member := range t {
+			if !isMessagesType(member) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func unsupportedRuleError(rule, valueType string) error {
+	return fmt.Errorf("unsupported value of %q rule: %s, expected a set of messages, a string or a boolean", rule, valueType)
+}
+
+func policyStorageKey(name string) string {
+	return StorageKeyPrefixPolicy + name
+}
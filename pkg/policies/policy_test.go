@@ -0,0 +1,188 @@
+package policies
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Evaluate(t *testing.T) {
+	input := map[string]interface{}{
+		"resource_changes": []interface{}{
+			map[string]interface{}{"address": "vault_mount.kv", "change": map[string]interface{}{"actions": []interface{}{"delete"}}},
+		},
+		"deny_value": map[string]interface{}{"address": "vault_mount.kv"},
+	}
+
+	tests := []struct {
+		description  string
+		source       string
+		expected     *Result
+		compileError string
+		evalError    string
+	}{
+		{
+			description: "set of messages",
+			source: `package gitops
+
+deny contains msg if {
+	some change in input.resource_changes
+	"delete" in change.change.actions
+	msg := sprintf("%s is removed", [change.address])
+}
+
+warn contains "plan has changes" if count(input.resource_changes) > 0`,
+			expected: &Result{Deny: []string{"vault_mount.kv is removed"}, Warn: []string{"plan has changes"}},
+		},
+		{
+			description: "boolean rule",
+			source: `package gitops
+
+deny if {
+	some change in input.resource_changes
+	"delete" in change.change.actions
+}`,
+			expected: &Result{Deny: []string{"deny rule matched"}},
+		},
+		{
+			description: "boolean rule is not matched",
+			source: `package gitops
+
+default deny := false`,
+			expected: &Result{},
+		},
+		{
+			description: "string rule",
+			source: `package gitops
+
+deny := "removing mounts is not allowed" if count(input.resource_changes) > 0`,
+			expected: &Result{Deny: []string{"removing mounts is not allowed"}},
+		},
+		{
+			description: "no rules for the input",
+			source: `package gitops
+
+deny contains "never" if false`,
+			expected: &Result{},
+		},
+		{
+			description: "object rule",
+			source: `package gitops
+
+deny := {"address": "vault_mount.kv"}`,
+			compileError: `unsupported value of "deny" rule`,
+		},
+		{
+			description: "object value of input",
+			source: `package gitops
+
+deny := input.deny_value`,
+			evalError: `unsupported value of "deny" rule: map[string]interface {}`,
+		},
+		{
+			description: "compile error",
+			source: `package gitops
+
+deny contains msg if {
+	msg := undefined_function(input)
+}`,
+			compileError: "undefined function undefined_function",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctx := context.Background()
+			storage := &logical.InmemStorage{}
+			require.NoError(t, PutPolicy(ctx, storage, &Policy{Name: "test", Source: tt.source}))
+
+			evaluator, err := NewEvaluator(ctx, storage)
+			if tt.compileError != "" {
+				require.ErrorContains(t, err, tt.compileError)
+				return
+			}
+			require.NoError(t, err)
+
+			result, err := evaluator.Evaluate(ctx, input)
+			if tt.evalError != "" {
+				require.ErrorContains(t, err, tt.evalError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func Test_NewEvaluator_NoPolicies(t *testing.T) {
+	evaluator, err := NewEvaluator(context.Background(), &logical.InmemStorage{})
+	require.NoError(t, err)
+	require.Nil(t, evaluator)
+}
+
+func Test_resultFromValue(t *testing.T) {
+	tests := []struct {
+		description string
+		value       interface{}
+		expected    *Result
+		err         string
+	}{
+		{
+			description: "undefined document",
+			value:       nil,
+			expected:    &Result{},
+		},
+		{
+			description: "sets of messages",
+			value: map[string]interface{}{
+				"deny": []interface{}{"vault_policy.admin grants sudo on sys/*", "vault_mount.kv is removed"},
+				"warn": []interface{}{"vault_policy.dev is changed"},
+			},
+			expected: &Result{
+				Deny: []string{"vault_mount.kv is removed", "vault_policy.admin grants sudo on sys/*"},
+				Warn: []string{"vault_policy.dev is changed"},
+			},
+		},
+		{
+			description: "non-string messages and other rules",
+			value: map[string]interface{}{
+				"deny":    []interface{}{map[string]interface{}{"address": "vault_mount.kv"}},
+				"helpers": true,
+			},
+			expected: &Result{
+				Deny: []string{"map[address:vault_mount.kv]"},
+			},
+		},
+		{
+			description: "boolean rules",
+			value: map[string]interface{}{
+				"deny": true,
+				"warn": false,
+			},
+			expected: &Result{
+				Deny: []string{"deny rule matched"},
+			},
+		},
+		{
+			description: "unsupported value",
+			value: map[string]interface{}{
+				"warn": 1,
+			},
+			err: `unsupported value of "warn" rule: int`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			result, err := resultFromValue(tt.value)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err+", expected a set of messages, a string or a boolean")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package resource_policy
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/fatih/structs"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameAllowedResourceTypes = "allowed_resource_types"
+	FieldNameDeniedResourceTypes  = "denied_resource_types"
+	FieldNameAllowedAddresses     = "allowed_addresses"
+	FieldNameDeniedAddresses      = "denied_addresses"
+
+	StorageKeyConfiguration = "resource_policy_configuration"
+)
+
+type Configuration struct {
+	AllowedResourceTypes []string `structs:"allowed_resource_types" json:"allowed_resource_types"`
+	DeniedResourceTypes  []string `structs:"denied_resource_types" json:"denied_resource_types"`
+	AllowedAddresses     []string `structs:"allowed_addresses" json:"allowed_addresses"`
+	DeniedAddresses      []string `structs:"denied_addresses" json:"denied_addresses"`
+}
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^configure/resource_policy/?$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameAllowedResourceTypes: {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Resource type patterns the plan may change, e.g. \"vault_policy,vault_kv_secret_*\". Empty allows any type.",
+				},
+				FieldNameDeniedResourceTypes: {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Resource type patterns the plan may not change.",
+				},
+				FieldNameAllowedAddresses: {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Resource address patterns the plan may change in addition to allowed types.",
+				},
+				FieldNameDeniedAddresses: {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Resource address patterns the plan may not change, e.g. \"vault_generic_endpoint.sys_*\".",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Create resource policy configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Update the current resource policy configuration.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathConfigureRead,
+					Summary:  "Read the current resource policy configuration.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathConfigureDelete,
+					Summary:  "Delete the current resource policy configuration.",
+				},
+			},
+			ExistenceCheck:  b.pathConfigExistenceCheck,
+			HelpSynopsis:    configureHelpSyn,
+			HelpDescription: configureHelpDesc,
+		},
+	}
+}
+
+// pathConfigExistenceCheck verifies if the configuration exists.
+func (b *backend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Resource policy configuration started")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get existing configuration: %s", err), nil
+	}
+	if config == nil {
+		config = &Configuration{}
+	}
+
+	for name, value := range map[string]*[]string{
+		FieldNameAllowedResourceTypes: &config.AllowedResourceTypes,
+		FieldNameDeniedResourceTypes:  &config.DeniedResourceTypes,
+		FieldNameAllowedAddresses:     &config.AllowedAddresses,
+		FieldNameDeniedAddresses:      &config.DeniedAddresses,
+	} {
+		if patterns, ok := fields.GetOk(name); ok {
+			*value = patterns.([]string)
+		}
+		for _, pattern := range *value {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return logical.ErrorResponse("%q field value %q is invalid: %s", name, pattern, err), nil
+			}
+		}
+	}
+
+	if err := putConfiguration(ctx, req.Storage, *config); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigureRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Reading resource policy configuration")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get Configuration: %s", err), nil
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{Data: structs.Map(config)}, nil
+}
+
+func (b *backend) pathConfigureDelete(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Debug("Deleting resource policy configuration")
+
+	if err := req.Storage.Delete(ctx, StorageKeyConfiguration); err != nil {
+		return logical.ErrorResponse("Unable to delete Configuration: %s", err), nil
+	}
+
+	return nil, nil
+}
+
+func putConfiguration(ctx context.Context, storage logical.Storage, config Configuration) error {
+	storageEntry, err := logical.StorageEntryJSON(StorageKeyConfiguration, config)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, storageEntry)
+}
+
+// GetConfig returns nil if the resource policy is not configured
+func GetConfig(ctx context.Context, storage logical.Storage) (*Configuration, error) {
+	storageEntry, err := storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get Configuration: %w", err)
+	}
+	if storageEntry == nil {
+		return nil, nil
+	}
+
+	var config *Configuration
+	if err := storageEntry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+const (
+	configureHelpSyn = `
+Resource types and addresses the plan of the gitops_terraform backend may change.
+`
+	configureHelpDesc = `
+Every plan is checked before apply. A changed resource violates the policy if
+its type or address matches a denied pattern, or if allowed types or addresses
+are set and it matches none of them. Patterns use "*" for any part. A plan
+with violations is not applied and the run fails listing offending addresses.
+`
+)
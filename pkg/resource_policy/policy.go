@@ -0,0 +1,62 @@
+package resource_policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+// ViolationError lists resources of the plan which violate the policy
+type ViolationError struct {
+	Violations []string
+}
+
+func (e *ViolationError) Error() string {
+	return fmt.Sprintf("plan violates resource policy: %s", strings.Join(e.Violations, "; "))
+}
+
+// Check returns ViolationError if the plan changes resources not allowed by the policy
+func (c *Configuration) Check(changes []runs.ResourceChange) error {
+	var violations []string
+	for _, change := range changes {
+		if runs.ChangeAction(change.Actions) == "" {
+			continue
+		}
+		if reason := c.violation(change); reason != "" {
+			violations = append(violations, fmt.Sprintf("%s (%s)", change.Address, reason))
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ViolationError{Violations: violations}
+	}
+	return nil
+}
+
+// violation returns the reason the resource can not be changed, empty if it can
+func (c *Configuration) violation(change runs.ResourceChange) string {
+	switch {
+	case matchAny(c.DeniedResourceTypes, change.Type):
+		return fmt.Sprintf("resource type %q is denied", change.Type)
+	case matchAny(c.DeniedAddresses, change.Address):
+		return "address is denied"
+	case len(c.AllowedResourceTypes) == 0 && len(c.AllowedAddresses) == 0:
+		return ""
+	case matchAny(c.AllowedResourceTypes, change.Type) || matchAny(c.AllowedAddresses, change.Address):
+		return ""
+	default:
+		return fmt.Sprintf("resource type %q is not allowed", change.Type)
+	}
+}
+
+// matchAny reports whether the value matches any of the patterns, patterns are validated on configuration
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package resource_policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+func Test_Check(t *testing.T) {
+	changes := []runs.ResourceChange{
+		{Address: "vault_policy.dev", Type: "vault_policy", Actions: []string{"create"}},
+		{Address: "vault_kv_secret_v2.app", Type: "vault_kv_secret_v2", Actions: []string{"update"}},
+		{Address: "vault_generic_endpoint.sys_audit", Type: "vault_generic_endpoint", Actions: []string{"create"}},
+		{Address: "vault_generic_endpoint.app_config", Type: "vault_generic_endpoint", Actions: []string{"update"}},
+		{Address: "vault_token_auth_backend_role.ci", Type: "vault_token_auth_backend_role", Actions: []string{"no-op"}},
+	}
+
+	tests := []struct {
+		description string
+		config      *Configuration
+		expected    []string
+	}{
+		{
+			description: "empty policy allows everything",
+			config:      &Configuration{},
+		},
+		{
+			description: "denied types and addresses",
+			config: &Configuration{
+				DeniedResourceTypes: []string{"vault_token_auth_backend_role"},
+				DeniedAddresses:     []string{"vault_generic_endpoint.sys_*"},
+			},
+			expected: []string{
+				"vault_generic_endpoint.sys_audit (address is denied)",
+			},
+		},
+		{
+			description: "allowed types and addresses",
+			config: &Configuration{
+				AllowedResourceTypes: []string{"vault_policy", "vault_kv_secret_*"},
+				AllowedAddresses:     []string{"vault_generic_endpoint.app_*"},
+			},
+			expected: []string{
+				`vault_generic_endpoint.sys_audit (resource type "vault_generic_endpoint" is not allowed)`,
+			},
+		},
+		{
+			description: "deny takes precedence over allow",
+			config: &Configuration{
+				AllowedResourceTypes: []string{"vault_*"},
+				DeniedResourceTypes:  []string{"vault_kv_secret_v2"},
+			},
+			expected: []string{
+				`vault_kv_secret_v2.app (resource type "vault_kv_secret_v2" is denied)`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			err := tt.config.Check(changes)
+			if tt.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			var violationErr *ViolationError
+			require.ErrorAs(t, err, &violationErr)
+			require.Equal(t, tt.expected, violationErr.Violations)
+		})
+	}
+}
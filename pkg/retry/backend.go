@@ -0,0 +1,204 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameMaxAttempts    = "max_attempts"
+	FieldNameInitialBackoff = "initial_backoff"
+	FieldNameMaxBackoff     = "max_backoff"
+
+	StorageKeyConfiguration = "retry_policy_configuration"
+
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = time.Minute
+	defaultMaxBackoff     = time.Hour
+)
+
+type Configuration struct {
+	MaxAttempts    int           `structs:"max_attempts" json:"max_attempts"`
+	InitialBackoff time.Duration `structs:"initial_backoff" json:"initial_backoff"`
+	MaxBackoff     time.Duration `structs:"max_backoff" json:"max_backoff"`
+}
+
+// Backoff returns delay before the next attempt after the given number of failed attempts
+func (c *Configuration) Backoff(attempts int) time.Duration {
+	backoff := c.InitialBackoff
+	for i := 1; i < attempts && backoff < c.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.MaxBackoff {
+		backoff = c.MaxBackoff
+	}
+	return backoff
+}
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^configure/retry_policy/?$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameMaxAttempts: {
+					Type:        framework.TypeInt,
+					Default:     defaultMaxAttempts,
+					Description: "Number of failed attempts after which the commit is quarantined.",
+				},
+				FieldNameInitialBackoff: {
+					Type:        framework.TypeDurationSecond,
+					Default:     int(defaultInitialBackoff.Seconds()),
+					Description: "Delay after the first failed attempt, doubled after each next one.",
+				},
+				FieldNameMaxBackoff: {
+					Type:        framework.TypeDurationSecond,
+					Default:     int(defaultMaxBackoff.Seconds()),
+					Description: "Maximum delay between attempts.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Create retry policy configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Update the current retry policy configuration.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathConfigureRead,
+					Summary:  "Read the current retry policy configuration.",
+				},
+			},
+			ExistenceCheck:  b.pathConfigExistenceCheck,
+			HelpSynopsis:    configureHelpSyn,
+			HelpDescription: configureHelpDesc,
+		},
+	}
+}
+
+// pathConfigExistenceCheck verifies if the configuration exists.
+func (b *backend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Retry policy configuration started")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get existing configuration: %s", err), nil
+	}
+
+	if maxAttempts, ok := fields.GetOk(FieldNameMaxAttempts); ok {
+		config.MaxAttempts = maxAttempts.(int)
+	}
+
+	if initialBackoff, ok := fields.GetOk(FieldNameInitialBackoff); ok {
+		config.InitialBackoff = time.Duration(initialBackoff.(int)) * time.Second
+	}
+
+	if maxBackoff, ok := fields.GetOk(FieldNameMaxBackoff); ok {
+		config.MaxBackoff = time.Duration(maxBackoff.(int)) * time.Second
+	}
+
+	if config.MaxAttempts < 1 {
+		return logical.ErrorResponse("%q field value should be positive", FieldNameMaxAttempts), nil
+	}
+
+	if config.InitialBackoff < 0 {
+		return logical.ErrorResponse("%q field value should not be negative", FieldNameInitialBackoff), nil
+	}
+
+	if config.MaxBackoff < config.InitialBackoff {
+		return logical.ErrorResponse("%q field value should not be less than %q", FieldNameMaxBackoff, FieldNameInitialBackoff), nil
+	}
+
+	if err := putConfiguration(ctx, req.Storage, *config); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigureRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Reading retry policy configuration")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get Configuration: %s", err), nil
+	}
+
+	return &logical.Response{Data: map[string]interface{}{
+		FieldNameMaxAttempts:    config.MaxAttempts,
+		FieldNameInitialBackoff: config.InitialBackoff.Seconds(),
+		FieldNameMaxBackoff:     config.MaxBackoff.Seconds(),
+	}}, nil
+}
+
+func putConfiguration(ctx context.Context, storage logical.Storage, config Configuration) error {
+	storageEntry, err := logical.StorageEntryJSON(StorageKeyConfiguration, config)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, storageEntry)
+}
+
+// GetConfig returns the retry policy configuration, defaults are used if it is not set
+func GetConfig(ctx context.Context, storage logical.Storage) (*Configuration, error) {
+	storageEntry, err := storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get Configuration: %w", err)
+	}
+	if storageEntry == nil {
+		return &Configuration{
+			MaxAttempts:    defaultMaxAttempts,
+			InitialBackoff: defaultInitialBackoff,
+			MaxBackoff:     defaultMaxBackoff,
+		}, nil
+	}
+
+	var config *Configuration
+	if err := storageEntry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+const (
+	configureHelpSyn = `
+Retry policy of failed commits.
+`
+	configureHelpDesc = `
+A commit which failed to apply is retried with exponential backoff starting
+from initial_backoff up to max_backoff. After max_attempts failures, or at once
+if the error is permanent (e.g. invalid configuration), the commit is
+quarantined: it is skipped until a newer signed commit is found or runs/retry
+is called.
+`
+)
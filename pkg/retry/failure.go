@@ -0,0 +1,171 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	storageKeyFailure = "commit_failure"
+)
+
+// Error messages of failures which may pass on the next attempt
+var transientErrorPatterns = []string{
+	"connection refused",
+	"connection reset",
+	"timeout",
+	"no such host",
+	"tls handshake",
+	"unexpected eof",
+	"too many requests",
+	"internal server error",
+	"bad gateway",
+	"service unavailable",
+	"code: 500",
+	"code: 502",
+	"code: 503",
+	"code: 504",
+	"state lock",
+	"locked",
+}
+
+// Failures which require a change of the commit to pass, checked before transientErrorPatterns
+// because their messages quote the configuration, e.g. An argument named "timeout" is not expected here
+var configurationErrorPatterns = []string{
+	"argument or block definition required",
+	"unsupported argument",
+	"unsupported block type",
+	"missing required argument",
+	"invalid expression",
+	"invalid reference",
+	"unclosed configuration block",
+	"reference to undeclared",
+	"validation rule",
+	"violates resource policy",
+	"denied by policy",
+	"denied by admission webhook",
+	"invalid commit directives",
+}
+
+// Error messages of failures which require a change of the commit to pass
+var permanentErrorPatterns = []string{
+	"invalid value",
+	"is not a directory",
+}
+
+// Error classes reported by status
+const (
+	ErrorClassPermanent = "permanent"
+	ErrorClassTransient = "transient"
+)
+
+// ErrorClass returns the class of the error, see IsPermanent
+func ErrorClass(err error) string {
+	if IsPermanent(err) {
+		return ErrorClassPermanent
+	}
+	return ErrorClassTransient
+}
+
+// IsPermanent returns true if retrying the same commit can not help
+// Unknown errors are treated as transient
+func IsPermanent(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, pattern := range configurationErrorPatterns {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	for _, pattern := range transientErrorPatterns {
+		if strings.Contains(message, pattern) {
+			return false
+		}
+	}
+	for _, pattern := range permanentErrorPatterns {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Failure is the retry state of the commit which failed to apply
+type Failure struct {
+	CommitHash    string    `json:"commit_hash"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error"`
+	Permanent     bool      `json:"permanent"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	Quarantined   bool      `json:"quarantined"`
+}
+
+// RecordFailure increments attempts of the commit and decides when to retry it
+func RecordFailure(ctx context.Context, storage logical.Storage, commitHash string, applyErr error, now time.Time) (*Failure, error) {
+	config, err := GetConfig(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	failure, err := GetFailure(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	if failure == nil || failure.CommitHash != commitHash {
+		failure = &Failure{CommitHash: commitHash}
+	}
+
+	failure.Attempts++
+	failure.LastError = applyErr.Error()
+	failure.Permanent = IsPermanent(applyErr)
+	failure.LastAttemptAt = now
+	failure.Quarantined = failure.Permanent || failure.Attempts >= config.MaxAttempts
+	if failure.Quarantined {
+		failure.NextAttemptAt = time.Time{}
+	} else {
+		failure.NextAttemptAt = now.Add(config.Backoff(failure.Attempts))
+	}
+
+	if err := util.PutJSON(ctx, storage, storageKeyFailure, failure); err != nil {
+		return nil, err
+	}
+
+	return failure, nil
+}
+
+// IsPassedOver returns true if the quarantined commit is passed over for newer commits of the sequence,
+// the newest commit stays quarantined until a newer commit appears
+func (f *Failure) IsPassedOver(commitHash string, hasNewerCommits bool) bool {
+	return f != nil && f.Quarantined && f.CommitHash == commitHash && hasNewerCommits
+}
+
+// GetFailure returns nil if the last processed commit did not fail
+func GetFailure(ctx context.Context, storage logical.Storage) (*Failure, error) {
+	var failure *Failure
+	if err := util.GetJSON(ctx, storage, storageKeyFailure, &failure); err != nil {
+		return nil, err
+	}
+	return failure, nil
+}
+
+// ClearFailure forgets failed attempts, the commit will be tried again by the next run
+func ClearFailure(ctx context.Context, storage logical.Storage) error {
+	return storage.Delete(ctx, storageKeyFailure)
+}
@@ -0,0 +1,157 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_IsPermanent(t *testing.T) {
+	tests := []struct {
+		description string
+		err         error
+		expected    bool
+	}{
+		{
+			description: "state lock",
+			err:         errors.New("terraform plan failed: Error: Error acquiring the state lock"),
+			expected:    false,
+		},
+		{
+			description: "server error",
+			err:         errors.New("terraform apply failed: Error: Code: 503. Errors: * Vault is sealed"),
+			expected:    false,
+		},
+		{
+			description: "deadline",
+			err:         fmt.Errorf("terraform apply: %w", context.DeadlineExceeded),
+			expected:    false,
+		},
+		{
+			description: "hcl syntax",
+			err:         errors.New("terraform init failed: Error: Argument or block definition required"),
+			expected:    true,
+		},
+		{
+			description: "hcl error quoting a transient word",
+			err:         errors.New(`terraform plan failed: Error: Unsupported argument: An argument named "timeout" is not expected here`),
+			expected:    true,
+		},
+		{
+			description: "variable validation",
+			err:         errors.New("terraform plan failed: Error: Invalid value for variable: This was checked by the validation rule at main.tf:3,3-13"),
+			expected:    true,
+		},
+		{
+			description: "provider timeout",
+			err:         errors.New("terraform apply failed: Error: error writing policy: context deadline exceeded (Client.Timeout exceeded while awaiting headers)"),
+			expected:    false,
+		},
+		{
+			description: "unsupported block",
+			err:         errors.New("terraform plan failed: Error: Unsupported block type"),
+			expected:    true,
+		},
+		{
+			description: "resource policy",
+			err:         errors.New("unable to apply terraform configuration: plan violates resource policy: vault_mount.kv (address is denied)"),
+			expected:    true,
+		},
+		{
+			description: "policy denial of a resource named like a transient error",
+			err:         errors.New("unable to apply terraform configuration: plan violates resource policy: vault_mount.locked (address is denied)"),
+			expected:    true,
+		},
+		{
+			description: "admission webhook denial",
+			err:         errors.New("unable to apply terraform configuration: plan denied by admission webhook: change window is closed"),
+			expected:    true,
+		},
+		{
+			description: "admission webhook failure",
+			err:         errors.New("unable to apply terraform configuration: admission webhook failed: unexpected response status 502: bad gateway"),
+			expected:    false,
+		},
+		{
+			description: "commit directives",
+			err:         errors.New(`invalid commit directives: unknown directive "Gitops-Force"`),
+			expected:    true,
+		},
+		{
+			description: "unknown error",
+			err:         errors.New("something went wrong"),
+			expected:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			require.Equal(t, tt.expected, IsPermanent(tt.err))
+
+			expectedClass := ErrorClassTransient
+			if tt.expected {
+				expectedClass = ErrorClassPermanent
+			}
+			require.Equal(t, expectedClass, ErrorClass(tt.err))
+		})
+	}
+}
+
+func Test_Backoff(t *testing.T) {
+	config := &Configuration{InitialBackoff: time.Minute, MaxBackoff: 5 * time.Minute}
+
+	require.Equal(t, time.Minute, config.Backoff(1))
+	require.Equal(t, 2*time.Minute, config.Backoff(2))
+	require.Equal(t, 4*time.Minute, config.Backoff(3))
+	require.Equal(t, 5*time.Minute, config.Backoff(4))
+	require.Equal(t, 5*time.Minute, config.Backoff(100))
+}
+
+func Test_RecordFailure(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+	transientErr := errors.New("connection refused")
+
+	require.NoError(t, putConfiguration(ctx, storage, Configuration{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Hour}))
+
+	failure, err := RecordFailure(ctx, storage, "a", transientErr, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, failure.Attempts)
+	require.False(t, failure.Quarantined)
+	require.Equal(t, now.Add(time.Minute), failure.NextAttemptAt)
+
+	failure, err = RecordFailure(ctx, storage, "a", transientErr, now)
+	require.NoError(t, err)
+	require.Equal(t, 2, failure.Attempts)
+	require.Equal(t, now.Add(2*time.Minute), failure.NextAttemptAt)
+
+	failure, err = RecordFailure(ctx, storage, "a", transientErr, now)
+	require.NoError(t, err)
+	require.True(t, failure.Quarantined, "max attempts reached")
+
+	failure, err = RecordFailure(ctx, storage, "b", transientErr, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, failure.Attempts, "new commit resets attempts")
+	require.False(t, failure.Quarantined)
+
+	failure, err = RecordFailure(ctx, storage, "c", errors.New("Error: Unsupported argument"), now)
+	require.NoError(t, err)
+	require.True(t, failure.Permanent)
+	require.True(t, failure.Quarantined, "permanent error quarantines at once")
+
+	require.True(t, failure.IsPassedOver("c", true), "quarantined commit is passed over for a newer one")
+	require.False(t, failure.IsPassedOver("c", false), "the newest commit stays quarantined")
+	require.False(t, failure.IsPassedOver("a", true), "commit without failure is not passed over")
+
+	require.NoError(t, ClearFailure(ctx, storage))
+	failure, err = GetFailure(ctx, storage)
+	require.NoError(t, err)
+	require.Nil(t, failure)
+	require.False(t, failure.IsPassedOver("c", true))
+}
@@ -0,0 +1,282 @@
+package runs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameRunID   = "run_id"
+	FieldNameMaxRuns = "max_runs"
+	FieldNameMaxAge  = "max_age"
+
+	StorageKeyConfiguration = "run_history_configuration"
+
+	defaultMaxRuns = 100
+	defaultMaxAge  = 30 * 24 * time.Hour
+)
+
+type Configuration struct {
+	MaxRuns int           `structs:"max_runs" json:"max_runs"`
+	MaxAge  time.Duration `structs:"max_age" json:"max_age"`
+}
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^runs/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathRunsList,
+					Summary:  "List runs.",
+				},
+			},
+			HelpSynopsis:    runsHelpSyn,
+			HelpDescription: runsHelpDesc,
+		},
+		{
+			Pattern: "^runs/" + framework.GenericNameRegex(FieldNameRunID) + "$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameRunID: {
+					Type:        framework.TypeString,
+					Description: "Run identifier.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathRunRead,
+					Summary:  "Read the run.",
+				},
+			},
+			HelpSynopsis:    runsHelpSyn,
+			HelpDescription: runsHelpDesc,
+		},
+		{
+			Pattern: "^configure/run_history/?$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameMaxRuns: {
+					Type:        framework.TypeInt,
+					Default:     defaultMaxRuns,
+					Description: "Maximum number of runs to keep. 0 means unlimited.",
+				},
+				FieldNameMaxAge: {
+					Type:        framework.TypeDurationSecond,
+					Default:     int(defaultMaxAge.Seconds()),
+					Description: "Maximum age of runs to keep. 0 means unlimited.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Create run history configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Update the current run history configuration.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathConfigureRead,
+					Summary:  "Read the current run history configuration.",
+				},
+			},
+			ExistenceCheck:  b.pathConfigExistenceCheck,
+			HelpSynopsis:    configureHelpSyn,
+			HelpDescription: configureHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathRunsList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	runList, err := ListRuns(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to list runs: %s", err), nil
+	}
+
+	keys := make([]string, 0, len(runList))
+	keyInfo := make(map[string]interface{}, len(runList))
+	for _, run := range runList {
+		keys = append(keys, run.ID)
+		keyInfo[run.ID] = map[string]interface{}{
+			"workspace":   run.Workspace,
+			"trigger":     run.Trigger,
+			"status":      run.Status,
+			"commit_hash": run.CommitHash,
+			"started_at":  formatTime(run.StartedAt),
+		}
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *backend) pathRunRead(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	run, err := GetRun(ctx, req.Storage, fields.Get(FieldNameRunID).(string))
+	if err != nil {
+		return logical.ErrorResponse("Unable to get run: %s", err), nil
+	}
+	if run == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{Data: RunToMap(run)}, nil
+}
+
+// RunToMap converts run to response data
+func RunToMap(run *Run) map[string]interface{} {
+	data := map[string]interface{}{
+		"id":              run.ID,
+		"workspace":       run.Workspace,
+		"trigger":         run.Trigger,
+		"status":          run.Status,
+		"phase":           run.Phase,
+		"commit_hash":     run.CommitHash,
+		"commit_date":     formatTime(run.CommitDate),
+		"commit_author":   run.CommitAuthor,
+		"signers":         run.Signers,
+		"started_at":      formatTime(run.StartedAt),
+		"finished_at":     formatTime(run.FinishedAt),
+		"duration":        run.Duration().Seconds(),
+		"error":           run.Error,
+		"drift_changes":   run.DriftChanges,
+		"policy_denials":  run.PolicyDenials,
+		"policy_warnings": run.PolicyWarnings,
+	}
+	if run.SkipReason != "" {
+		data["skip_reason"] = run.SkipReason
+	}
+	if run.Directives != nil {
+		data["directives"] = run.Directives
+	}
+	if run.AdmissionDecision != nil {
+		data["admission_decision"] = run.AdmissionDecision
+	}
+	if run.PlanSummary != nil {
+		data["plan_summary"] = run.PlanSummary
+		data["plan_summary_text"] = run.PlanSummary.String()
+	}
+	return data
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// pathConfigExistenceCheck verifies if the configuration exists.
+func (b *backend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Run history configuration started")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get existing configuration: %s", err), nil
+	}
+
+	if maxRuns, ok := fields.GetOk(FieldNameMaxRuns); ok {
+		config.MaxRuns = maxRuns.(int)
+	}
+
+	if maxAge, ok := fields.GetOk(FieldNameMaxAge); ok {
+		config.MaxAge = time.Duration(maxAge.(int)) * time.Second
+	}
+
+	if config.MaxRuns < 0 {
+		return logical.ErrorResponse("%q field value should not be negative", FieldNameMaxRuns), nil
+	}
+
+	if config.MaxAge < 0 {
+		return logical.ErrorResponse("%q field value should not be negative", FieldNameMaxAge), nil
+	}
+
+	if err := putConfiguration(ctx, req.Storage, *config); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigureRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Reading run history configuration")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get Configuration: %s", err), nil
+	}
+
+	return &logical.Response{Data: map[string]interface{}{
+		FieldNameMaxRuns: config.MaxRuns,
+		FieldNameMaxAge:  config.MaxAge.Seconds(),
+	}}, nil
+}
+
+func putConfiguration(ctx context.Context, storage logical.Storage, config Configuration) error {
+	storageEntry, err := logical.StorageEntryJSON(StorageKeyConfiguration, config)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, storageEntry)
+}
+
+// GetConfig returns the run history configuration, defaults are used if it is not set
+func GetConfig(ctx context.Context, storage logical.Storage) (*Configuration, error) {
+	storageEntry, err := storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get Configuration: %w", err)
+	}
+	if storageEntry == nil {
+		return &Configuration{MaxRuns: defaultMaxRuns, MaxAge: defaultMaxAge}, nil
+	}
+
+	var config *Configuration
+	if err := storageEntry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+const (
+	runsHelpSyn = `
+History of gitops runs.
+`
+	runsHelpDesc = `
+Every processing of the git repository is recorded as a run with the trigger
+source, the processed commit, timestamps, the phase reached (clone, verify,
+init, plan, apply) and the error if the run failed.
+`
+	configureHelpSyn = `
+Run history configuration of the gitops_terraform backend.
+`
+	configureHelpDesc = `
+Finished runs exceeding max_runs or older than max_age are removed by the
+periodic function.
+`
+)
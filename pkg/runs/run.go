@@ -0,0 +1,214 @@
+package runs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/directives"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+// Phases of a run in the order they are reached
+const (
+	PhaseClone  = "clone"
+	PhaseVerify = "verify"
+	PhaseInit   = "init"
+	PhasePlan   = "plan"
+	PhaseApply  = "apply"
+)
+
+// Run statuses
+const (
+	StatusRunning     = "running"
+	StatusSucceeded   = "succeeded"
+	StatusFailed      = "failed"
+	StatusNoNewCommit = "no_new_commit"
+	StatusPaused      = "paused"
+	StatusDeferred    = "deferred"
+	StatusBackoff     = "backoff"
+	StatusQuarantined = "quarantined"
+	StatusCancelled   = "cancelled"
+	// StatusDriftDetected is set when drift is found and not remediated
+	StatusDriftDetected = "drift_detected"
+	// StatusAwaitingApproval is set when the commit is planned and the plan is not approved yet
+	StatusAwaitingApproval = "awaiting_approval"
+	// StatusBlocked is set when the plan is blocked by the blast-radius guard
+	StatusBlocked = "blocked"
+	// StatusLeaseHeld is set when another node holds the run lease of the workspace
+	StatusLeaseHeld = "lease_held"
+	// StatusSkipped is set when the commit is finished without running terraform, see Run.SkipReason
+	StatusSkipped = "skipped"
+	// StatusPlanned is set when the commit is only planned by the Gitops-Mode: plan-only directive
+	StatusPlanned = "planned"
+)
+
+const (
+	StorageKeyPrefixRun = "runs/"
+)
+
+// ResourceChange is a resource which terraform plan changes
+type ResourceChange struct {
+	Address string   `json:"address"`
+	Type    string   `json:"type"`
+	Actions []string `json:"actions"`
+}
+
+// Run is a single processGit invocation
+type Run struct {
+	ID         string    `json:"id"`
+	Workspace  string    `json:"workspace,omitempty"`
+	Trigger    string    `json:"trigger"`
+	Status     string    `json:"status"`
+	Phase      string    `json:"phase"`
+	CommitHash string    `json:"commit_hash,omitempty"`
+	CommitDate time.Time `json:"commit_date"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"`
+	// SkipReason explains why the commit was finished without running terraform
+	SkipReason string `json:"skip_reason,omitempty"`
+	// CommitAuthor and Signers (names of trusted keys which signed the commit) are set once the commit is cloned
+	CommitAuthor string   `json:"commit_author,omitempty"`
+	Signers      []string `json:"signers,omitempty"`
+	// Directives are the processing options given by the commit message
+	Directives *directives.Options `json:"directives,omitempty"`
+	// DriftChanges are resources changed outside of the repository found by the drift check
+	DriftChanges []ResourceChange `json:"drift_changes,omitempty"`
+	// PlanSummary describes what the plan of the commit changes
+	PlanSummary *PlanSummary `json:"plan_summary,omitempty"`
+	// PolicyDenials and PolicyWarnings are messages of Rego policies evaluated against the plan
+	PolicyDenials  []string `json:"policy_denials,omitempty"`
+	PolicyWarnings []string `json:"policy_warnings,omitempty"`
+	// AdmissionDecision is the decision of the external admission webhook on the plan
+	AdmissionDecision *AdmissionDecision `json:"admission_decision,omitempty"`
+}
+
+// AdmissionDecision is the decision of the external admission webhook
+type AdmissionDecision struct {
+	URL     string `json:"url"`
+	Allowed bool   `json:"allowed"`
+	Message string `json:"message,omitempty"`
+	// Error is set if the webhook failed, then Allowed follows fail_open
+	Error     string    `json:"error,omitempty"`
+	DecidedAt time.Time `json:"decided_at"`
+}
+
+// Duration returns run duration, zero for unfinished runs
+func (r *Run) Duration() time.Duration {
+	if r.FinishedAt.IsZero() {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+func PutRun(ctx context.Context, storage logical.Storage, run *Run) error {
+	return util.PutJSON(ctx, storage, runStorageKey(run.ID), run)
+}
+
+// GetRun returns run by id or nil if it does not exist
+func GetRun(ctx context.Context, storage logical.Storage, id string) (*Run, error) {
+	var run *Run
+	if err := util.GetJSON(ctx, storage, runStorageKey(id), &run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func DeleteRun(ctx context.Context, storage logical.Storage, id string) error {
+	return storage.Delete(ctx, runStorageKey(id))
+}
+
+// ListRuns returns all stored runs, the most recent first
+func ListRuns(ctx context.Context, storage logical.Storage) ([]*Run, error) {
+	ids, err := storage.List(ctx, StorageKeyPrefixRun)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Run
+	for _, id := range ids {
+		run, err := GetRun(ctx, storage, id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get run %q: %w", id, err)
+		}
+		if run != nil {
+			result = append(result, run)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartedAt.After(result[j].StartedAt)
+	})
+
+	return result, nil
+}
+
+// Prune deletes finished runs exceeding retention limits. Returns number of deleted runs
+func Prune(ctx context.Context, storage logical.Storage, now time.Time) (int, error) {
+	config, err := GetConfig(ctx, storage)
+	if err != nil {
+		return 0, err
+	}
+
+	runList, err := ListRuns(ctx, storage)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	kept := 0
+	for _, run := range runList {
+		// never remove run in progress
+		if run.Status == StatusRunning {
+			kept++
+			continue
+		}
+
+		tooMany := config.MaxRuns > 0 && kept >= config.MaxRuns
+		tooOld := config.MaxAge > 0 && now.Sub(run.StartedAt) > config.MaxAge
+		if !tooMany && !tooOld {
+			kept++
+			continue
+		}
+
+		if err := DeleteRun(ctx, storage, run.ID); err != nil {
+			return deleted, fmt.Errorf("unable to delete run %q: %w", run.ID, err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// FinishInterrupted marks runs left running by a stopped plugin instance as cancelled. Returns number of such runs
+func FinishInterrupted(ctx context.Context, storage logical.Storage, now time.Time) (int, error) {
+	runList, err := ListRuns(ctx, storage)
+	if err != nil {
+		return 0, err
+	}
+
+	finished := 0
+	for _, run := range runList {
+		if run.Status != StatusRunning {
+			continue
+		}
+
+		run.Status = StatusCancelled
+		run.Error = "run was interrupted by restart of the plugin"
+		run.FinishedAt = now
+		if err := PutRun(ctx, storage, run); err != nil {
+			return finished, fmt.Errorf("unable to finish run %q: %w", run.ID, err)
+		}
+		finished++
+	}
+
+	return finished, nil
+}
+
+func runStorageKey(id string) string {
+	return StorageKeyPrefixRun + id
+}
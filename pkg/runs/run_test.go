@@ -0,0 +1,103 @@
+package runs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Prune(t *testing.T) {
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	type testcase struct {
+		description string
+		config      *Configuration
+		expectedIDs []string
+	}
+
+	tests := []testcase{
+		{
+			description: "default configuration keeps all recent runs",
+			expectedIDs: []string{"run-0", "run-1", "run-2", "run-3", "run-4"},
+		},
+		{
+			description: "max runs",
+			config:      &Configuration{MaxRuns: 2},
+			expectedIDs: []string{"run-0", "run-1"},
+		},
+		{
+			description: "max age",
+			config:      &Configuration{MaxAge: 150 * time.Minute},
+			expectedIDs: []string{"run-0", "run-1", "run-2"},
+		},
+		{
+			description: "unlimited",
+			config:      &Configuration{},
+			expectedIDs: []string{"run-0", "run-1", "run-2", "run-3", "run-4"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			ctx := context.Background()
+			storage := &logical.InmemStorage{}
+
+			if test.config != nil {
+				require.NoError(t, putConfiguration(ctx, storage, *test.config))
+			}
+
+			// run-0 is still running, run-N started N hours ago
+			for i := 0; i < 5; i++ {
+				status := StatusSucceeded
+				if i == 0 {
+					status = StatusRunning
+				}
+				require.NoError(t, PutRun(ctx, storage, &Run{
+					ID:        fmt.Sprintf("run-%d", i),
+					Status:    status,
+					StartedAt: now.Add(-time.Duration(i) * time.Hour),
+				}))
+			}
+
+			_, err := Prune(ctx, storage, now)
+			require.NoError(t, err)
+
+			runList, err := ListRuns(ctx, storage)
+			require.NoError(t, err)
+
+			var ids []string
+			for _, run := range runList {
+				ids = append(ids, run.ID)
+			}
+			require.Equal(t, test.expectedIDs, ids)
+		})
+	}
+}
+
+func Test_FinishInterrupted(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, PutRun(ctx, storage, &Run{ID: "running", Status: StatusRunning, StartedAt: now.Add(-time.Hour)}))
+	require.NoError(t, PutRun(ctx, storage, &Run{ID: "failed", Status: StatusFailed, StartedAt: now.Add(-2 * time.Hour)}))
+
+	finished, err := FinishInterrupted(ctx, storage, now)
+	require.NoError(t, err)
+	require.Equal(t, 1, finished)
+
+	run, err := GetRun(ctx, storage, "running")
+	require.NoError(t, err)
+	require.Equal(t, StatusCancelled, run.Status)
+	require.Equal(t, now, run.FinishedAt)
+	require.NotEmpty(t, run.Error)
+
+	run, err = GetRun(ctx, storage, "failed")
+	require.NoError(t, err)
+	require.Equal(t, StatusFailed, run.Status)
+	require.True(t, run.FinishedAt.IsZero())
+}
@@ -0,0 +1,110 @@
+package runs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Actions of the plan summary
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionReplace = "replace"
+)
+
+// ActionCounts is the number of resources per action
+type ActionCounts struct {
+	Create  int `json:"create"`
+	Update  int `json:"update"`
+	Delete  int `json:"delete"`
+	Replace int `json:"replace"`
+}
+
+func (c *ActionCounts) add(action string) {
+	switch action {
+	case ActionCreate:
+		c.Create++
+	case ActionUpdate:
+		c.Update++
+	case ActionDelete:
+		c.Delete++
+	case ActionReplace:
+		c.Replace++
+	}
+}
+
+// PlanSummary describes what the plan changes
+type PlanSummary struct {
+	ActionCounts
+	// ResourceTypes are counts per resource type, e.g. vault_policy
+	ResourceTypes map[string]*ActionCounts `json:"resource_types"`
+	// Addresses are sorted addresses of changed resources
+	Addresses []string `json:"addresses"`
+}
+
+// NewPlanSummary counts changes of the plan
+func NewPlanSummary(changes []ResourceChange) *PlanSummary {
+	summary := &PlanSummary{
+		ResourceTypes: map[string]*ActionCounts{},
+		Addresses:     []string{},
+	}
+
+	for _, change := range changes {
+		action := ChangeAction(change.Actions)
+		if action == "" {
+			continue
+		}
+
+		summary.add(action)
+		if _, ok := summary.ResourceTypes[change.Type]; !ok {
+			summary.ResourceTypes[change.Type] = &ActionCounts{}
+		}
+		summary.ResourceTypes[change.Type].add(action)
+		summary.Addresses = append(summary.Addresses, change.Address)
+	}
+	sort.Strings(summary.Addresses)
+
+	return summary
+}
+
+// ChangeAction reduces terraform actions of the resource to a single action,
+// empty for no-op and read
+func ChangeAction(actions []string) string {
+	switch {
+	case len(actions) == 2:
+		// ["delete", "create"] or ["create", "delete"]
+		return ActionReplace
+	case len(actions) != 1:
+		return ""
+	}
+
+	switch actions[0] {
+	case ActionCreate, ActionUpdate, ActionDelete:
+		return actions[0]
+	}
+	return ""
+}
+
+// String returns human-readable summary in the style of terraform plan
+func (s *PlanSummary) String() string {
+	if s.Create+s.Update+s.Delete+s.Replace == 0 {
+		return "No changes."
+	}
+
+	var types []string
+	for resourceType := range s.ResourceTypes {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d to create, %d to update, %d to replace, %d to delete.", s.Create, s.Update, s.Replace, s.Delete)
+	for _, resourceType := range types {
+		counts := s.ResourceTypes[resourceType]
+		fmt.Fprintf(&sb, "\n  %s: %d to create, %d to update, %d to replace, %d to delete", resourceType, counts.Create, counts.Update, counts.Replace, counts.Delete)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,31 @@
+package runs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewPlanSummary(t *testing.T) {
+	changes := []ResourceChange{
+		{Address: "vault_policy.dev", Type: "vault_policy", Actions: []string{"create"}},
+		{Address: "vault_policy.admin", Type: "vault_policy", Actions: []string{"update"}},
+		{Address: "vault_mount.kv", Type: "vault_mount", Actions: []string{"delete", "create"}},
+		{Address: "vault_mount.old", Type: "vault_mount", Actions: []string{"delete"}},
+		{Address: "vault_mount.same", Type: "vault_mount", Actions: []string{"no-op"}},
+	}
+
+	summary := NewPlanSummary(changes)
+
+	require.Equal(t, ActionCounts{Create: 1, Update: 1, Delete: 1, Replace: 1}, summary.ActionCounts)
+	require.Equal(t, map[string]*ActionCounts{
+		"vault_policy": {Create: 1, Update: 1},
+		"vault_mount":  {Delete: 1, Replace: 1},
+	}, summary.ResourceTypes)
+	require.Equal(t, []string{"vault_mount.kv", "vault_mount.old", "vault_policy.admin", "vault_policy.dev"}, summary.Addresses)
+	require.Equal(t, "1 to create, 1 to update, 1 to replace, 1 to delete.\n"+
+		"  vault_mount: 0 to create, 0 to update, 1 to replace, 1 to delete\n"+
+		"  vault_policy: 1 to create, 1 to update, 0 to replace, 0 to delete", summary.String())
+
+	require.Equal(t, "No changes.", NewPlanSummary(nil).String())
+}
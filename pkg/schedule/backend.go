@@ -0,0 +1,191 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatih/structs"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameWindows  = "windows"
+	FieldNameFreezes  = "freezes"
+	FieldNameTimeZone = "time_zone"
+
+	StorageKeyConfiguration = "schedule_configuration"
+)
+
+type Configuration struct {
+	Windows  []string `structs:"windows" json:"windows"`
+	Freezes  []string `structs:"freezes" json:"freezes"`
+	TimeZone string   `structs:"time_zone" json:"time_zone"`
+}
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^configure/schedule/?$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameWindows: {
+					Type: framework.TypeCommaStringSlice,
+					Description: "Allowed windows as standard 5-field cron expressions, e.g. \"* 9-17 * * 1-5\". " +
+						"A minute is allowed if it matches any window. Empty means always allowed.",
+				},
+				FieldNameFreezes: {
+					Type: framework.TypeCommaStringSlice,
+					Description: "Freeze ranges when applying is forbidden: \"2024-12-20/2025-01-08\" (inclusive dates) " +
+						"or \"2024-12-20T18:00:00Z/2025-01-08T09:00:00Z\" (RFC3339 times).",
+				},
+				FieldNameTimeZone: {
+					Type:        framework.TypeString,
+					Default:     "UTC",
+					Description: "IANA time zone of windows and freeze dates, e.g. \"Europe/Moscow\".",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Create schedule configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Update the current schedule configuration.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathConfigureRead,
+					Summary:  "Read the current schedule configuration.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathConfigureDelete,
+					Summary:  "Delete the current schedule configuration.",
+				},
+			},
+			ExistenceCheck:  b.pathConfigExistenceCheck,
+			HelpSynopsis:    configureHelpSyn,
+			HelpDescription: configureHelpDesc,
+		},
+	}
+}
+
+// pathConfigExistenceCheck verifies if the configuration exists.
+func (b *backend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	out, err := req.Storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Schedule configuration started")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get existing configuration: %s", err), nil
+	}
+	if config == nil {
+		config = &Configuration{TimeZone: fields.GetDefaultOrZero(FieldNameTimeZone).(string)}
+	}
+
+	if windows, ok := fields.GetOk(FieldNameWindows); ok {
+		config.Windows = windows.([]string)
+	}
+
+	if freezes, ok := fields.GetOk(FieldNameFreezes); ok {
+		config.Freezes = freezes.([]string)
+	}
+
+	if timeZone, ok := fields.GetOk(FieldNameTimeZone); ok {
+		config.TimeZone = timeZone.(string)
+	}
+
+	if _, err := Parse(config); err != nil {
+		return logical.ErrorResponse("Invalid schedule: %s", err), nil
+	}
+
+	if err := putConfiguration(ctx, req.Storage, *config); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigureRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Reading schedule configuration")
+
+	config, err := GetConfig(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get Configuration: %s", err), nil
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{Data: structs.Map(config)}, nil
+}
+
+func (b *backend) pathConfigureDelete(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Debug("Deleting schedule configuration")
+
+	if err := req.Storage.Delete(ctx, StorageKeyConfiguration); err != nil {
+		return logical.ErrorResponse("Unable to delete Configuration: %s", err), nil
+	}
+
+	return nil, nil
+}
+
+func putConfiguration(ctx context.Context, storage logical.Storage, config Configuration) error {
+	storageEntry, err := logical.StorageEntryJSON(StorageKeyConfiguration, config)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, storageEntry)
+}
+
+// GetConfig returns nil if the schedule is not configured
+func GetConfig(ctx context.Context, storage logical.Storage) (*Configuration, error) {
+	storageEntry, err := storage.Get(ctx, StorageKeyConfiguration)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get Configuration: %w", err)
+	}
+	if storageEntry == nil {
+		return nil, nil
+	}
+
+	var config *Configuration
+	if err := storageEntry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+const (
+	configureHelpSyn = `
+Maintenance windows and change freezes of the gitops_terraform backend.
+`
+	configureHelpDesc = `
+A new signed commit is applied only inside one of the allowed windows and
+outside all freeze ranges. Otherwise the commit stays pending and the status
+reports the time it is deferred until. Without the configuration commits are
+applied as soon as they are found.
+`
+)
@@ -0,0 +1,166 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones are resolved without relying on the host
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	// limits search of the next allowed time
+	maxNextAllowedIterations = 1000
+)
+
+// freeze is a half-open time range [start, end) when applying is forbidden
+type freeze struct {
+	start time.Time
+	end   time.Time
+}
+
+// Schedule is the parsed configuration ready for evaluation
+type Schedule struct {
+	location *time.Location
+	windows  []cron.Schedule
+	freezes  []freeze
+}
+
+// Parse validates configuration and returns schedule for evaluation
+func Parse(config *Configuration) (*Schedule, error) {
+	location := time.UTC
+	if config.TimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(config.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", config.TimeZone, err)
+		}
+	}
+
+	s := &Schedule{location: location}
+
+	for _, window := range config.Windows {
+		cronSchedule, err := cron.ParseStandard(window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", window, err)
+		}
+		s.windows = append(s.windows, cronSchedule)
+	}
+
+	for _, freezeRange := range config.Freezes {
+		f, err := parseFreeze(freezeRange, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid freeze %q: %w", freezeRange, err)
+		}
+		s.freezes = append(s.freezes, f)
+	}
+
+	return s, nil
+}
+
+// parseFreeze parses "<start>/<end>" range of dates (inclusive) or RFC3339 timestamps
+func parseFreeze(freezeRange string, location *time.Location) (freeze, error) {
+	parts := strings.Split(freezeRange, "/")
+	if len(parts) != 2 {
+		return freeze{}, fmt.Errorf("expected <start>/<end>")
+	}
+
+	start, startErr := time.ParseInLocation(dateLayout, parts[0], location)
+	end, endErr := time.ParseInLocation(dateLayout, parts[1], location)
+	if startErr == nil && endErr == nil {
+		// end date is inclusive
+		end = end.AddDate(0, 0, 1)
+	} else {
+		var err error
+		if start, err = time.Parse(time.RFC3339, parts[0]); err != nil {
+			return freeze{}, fmt.Errorf("start should be a date (%s) or RFC3339 time: %w", dateLayout, err)
+		}
+		if end, err = time.Parse(time.RFC3339, parts[1]); err != nil {
+			return freeze{}, fmt.Errorf("end should be a date (%s) or RFC3339 time: %w", dateLayout, err)
+		}
+	}
+
+	if !end.After(start) {
+		return freeze{}, fmt.Errorf("end should be after start")
+	}
+
+	return freeze{start: start, end: end}, nil
+}
+
+// NextAllowedTime returns zero time if applying is allowed now,
+// otherwise the time when the next allowed window starts
+func (s *Schedule) NextAllowedTime(clock util.Clock) (time.Time, error) {
+	now := clock.Now().In(s.location)
+	if s.isAllowed(now) {
+		return time.Time{}, nil
+	}
+
+	t := now
+	for i := 0; i < maxNextAllowedIterations; i++ {
+		if f := s.activeFreeze(t); f != nil {
+			t = f.end.In(s.location)
+		} else {
+			t = s.nextWindowStart(t)
+			if t.IsZero() {
+				break
+			}
+		}
+
+		if s.isAllowed(t) {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("no allowed window found")
+}
+
+func (s *Schedule) isAllowed(t time.Time) bool {
+	return s.activeFreeze(t) == nil && s.inWindow(t)
+}
+
+func (s *Schedule) activeFreeze(t time.Time) *freeze {
+	for i := range s.freezes {
+		if !t.Before(s.freezes[i].start) && t.Before(s.freezes[i].end) {
+			return &s.freezes[i]
+		}
+	}
+	return nil
+}
+
+// inWindow returns true if minute of t matches any window, no windows means always
+func (s *Schedule) inWindow(t time.Time) bool {
+	if len(s.windows) == 0 {
+		return true
+	}
+
+	minute := t.Truncate(time.Minute)
+	for _, window := range s.windows {
+		if window.Next(minute.Add(-time.Second)).Equal(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindowStart returns the earliest matching minute of any window after t
+func (s *Schedule) nextWindowStart(t time.Time) time.Time {
+	if len(s.windows) == 0 {
+		return t
+	}
+
+	var next time.Time
+	for _, window := range s.windows {
+		candidate := window.Next(t)
+		if candidate.IsZero() {
+			continue
+		}
+		if next.IsZero() || candidate.Before(next) {
+			next = candidate
+		}
+	}
+	return next
+}
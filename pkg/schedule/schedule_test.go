@@ -0,0 +1,103 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+func Test_NextAllowedTime(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	businessHours := []string{"* 9-17 * * 1-5"}
+
+	type testcase struct {
+		description   string
+		config        *Configuration
+		now           time.Time
+		expectedAllow bool
+		expectedNext  time.Time
+	}
+
+	tests := []testcase{
+		{
+			description:   "empty schedule always allows",
+			config:        &Configuration{},
+			now:           time.Date(2025, 12, 6, 3, 0, 0, 0, time.UTC),
+			expectedAllow: true,
+		},
+		{
+			description:   "inside window",
+			config:        &Configuration{Windows: businessHours},
+			now:           time.Date(2025, 12, 3, 10, 30, 15, 0, time.UTC),
+			expectedAllow: true,
+		},
+		{
+			description:  "after window on friday waits for monday",
+			config:       &Configuration{Windows: businessHours},
+			now:          time.Date(2025, 12, 5, 18, 0, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 12, 8, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			description:  "window in time zone",
+			config:       &Configuration{Windows: businessHours, TimeZone: "Europe/Moscow"},
+			now:          time.Date(2025, 12, 3, 5, 0, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 12, 3, 9, 0, 0, 0, moscow),
+		},
+		{
+			description:  "freeze dates are inclusive",
+			config:       &Configuration{Freezes: []string{"2025-12-20/2026-01-08"}},
+			now:          time.Date(2026, 1, 8, 23, 59, 0, 0, time.UTC),
+			expectedNext: time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			description:  "freeze ends outside window",
+			config:       &Configuration{Windows: businessHours, Freezes: []string{"2025-12-20/2026-01-08"}},
+			now:          time.Date(2025, 12, 22, 12, 0, 0, 0, time.UTC),
+			expectedNext: time.Date(2026, 1, 9, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			description:  "window start inside freeze",
+			config:       &Configuration{Windows: businessHours, Freezes: []string{"2025-12-08T00:00:00Z/2025-12-08T12:00:00Z"}},
+			now:          time.Date(2025, 12, 8, 10, 0, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 12, 8, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			s, err := Parse(tt.config)
+			require.NoError(t, err)
+
+			clock, _ := util.NewMockedClock(tt.now)
+			next, err := s.NextAllowedTime(clock)
+			require.NoError(t, err)
+
+			if tt.expectedAllow {
+				require.True(t, next.IsZero(), "expected allowed, got deferred until %s", next)
+				return
+			}
+			require.True(t, tt.expectedNext.Equal(next), "expected %s, got %s", tt.expectedNext, next)
+		})
+	}
+}
+
+func Test_Parse_Invalid(t *testing.T) {
+	tests := map[string]*Configuration{
+		"bad cron":          {Windows: []string{"* * *"}},
+		"bad time zone":     {TimeZone: "Mars/Olympus"},
+		"bad freeze format": {Freezes: []string{"2025-12-20"}},
+		"reversed freeze":   {Freezes: []string{"2026-01-08/2025-12-20"}},
+	}
+
+	for description, config := range tests {
+		t.Run(description, func(t *testing.T) {
+			_, err := Parse(config)
+			require.Error(t, err)
+		})
+	}
+}
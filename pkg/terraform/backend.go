@@ -3,6 +3,7 @@ package terraform
 import (
 	"context"
 	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -10,18 +11,41 @@ import (
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
 )
 
 const (
 	FieldNameTfPath   = "terraform_path"
 	FieldNameTfBinary = "terraform_binary"
 
+	FieldNameWatchPaths = "watch_paths"
+
+	FieldNameRequireApproval = "require_approval"
+
+	FieldNameMaxDeletes                    = "max_deletes"
+	FieldNameMaxReplaces                   = "max_replaces"
+	FieldNameMaxChanges                    = "max_changes"
+	FieldNameProtectedResources            = "protected_resources"
+	FieldNameDestructiveRequiredSignatures = "destructive_required_signatures"
+
 	StorageKeyConfiguration = "terraform_configuration"
 )
 
 type Configuration struct {
 	TfPath   string `structs:"terraform_path" json:"terraform_path,omitempty"`
 	TfBinary string `structs:"terraform_binary" json:"terraform_binary,omitempty"`
+	// WatchPaths are paths besides TfPath, e.g. shared modules, changes of which are applied
+	WatchPaths []string `structs:"watch_paths" json:"watch_paths,omitempty"`
+	// RequireApproval stops after plan until the stored plan is approved
+	RequireApproval bool `structs:"require_approval" json:"require_approval,omitempty"`
+	// Blast-radius guard thresholds, 0 means unlimited
+	MaxDeletes         int      `structs:"max_deletes" json:"max_deletes,omitempty"`
+	MaxReplaces        int      `structs:"max_replaces" json:"max_replaces,omitempty"`
+	MaxChanges         int      `structs:"max_changes" json:"max_changes,omitempty"`
+	ProtectedResources []string `structs:"protected_resources" json:"protected_resources,omitempty"`
+	// DestructiveRequiredSignatures allows applying a plan exceeding thresholds, 0 means it is always blocked
+	DestructiveRequiredSignatures int `structs:"destructive_required_signatures" json:"destructive_required_signatures,omitempty"`
 }
 
 type backend struct {
@@ -54,6 +78,46 @@ func Paths(baseBackend *framework.Backend) []*framework.Path {
 					Description: "Full path to Terraform binary. Default is terraform.",
 					Required:    false,
 				},
+				FieldNameWatchPaths: {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Paths besides terraform_path, e.g. shared modules. A commit which changes no file under terraform_path and watch_paths is skipped.",
+					Required:    false,
+				},
+				FieldNameRequireApproval: {
+					Type:        framework.TypeBool,
+					Default:     false,
+					Description: "Store the plan of a new commit and apply it only after plans/<commit>/approve. Default is false.",
+					Required:    false,
+				},
+				FieldNameMaxDeletes: {
+					Type:        framework.TypeInt,
+					Default:     0,
+					Description: "Maximum number of resources the plan may delete. Default is 0 (unlimited).",
+					Required:    false,
+				},
+				FieldNameMaxReplaces: {
+					Type:        framework.TypeInt,
+					Default:     0,
+					Description: "Maximum number of resources the plan may replace. Default is 0 (unlimited).",
+					Required:    false,
+				},
+				FieldNameMaxChanges: {
+					Type:        framework.TypeInt,
+					Default:     0,
+					Description: "Maximum number of resources the plan may change in total. Default is 0 (unlimited).",
+					Required:    false,
+				},
+				FieldNameProtectedResources: {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Address patterns of resources which the plan may not delete or replace, e.g. \"vault_auth_backend.*\".",
+					Required:    false,
+				},
+				FieldNameDestructiveRequiredSignatures: {
+					Type:        framework.TypeInt,
+					Default:     0,
+					Description: "Number of verified signatures allowing to apply the plan exceeding the thresholds. Default is 0 (always blocked).",
+					Required:    false,
+				},
 			},
 
 			Operations: map[logical.Operation]framework.OperationHandler{
@@ -121,10 +185,55 @@ func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.
 		}
 	}
 
+	if watchPaths, ok := fields.GetOk(FieldNameWatchPaths); ok {
+		config.WatchPaths = watchPaths.([]string)
+	}
+	for _, watchPath := range config.WatchPaths {
+		if watchPath == "" || filepath.Clean(watchPath) != watchPath || strings.Contains(watchPath, "..") {
+			return logical.ErrorResponse("%q field value %q is invalid", FieldNameWatchPaths, watchPath), nil
+		}
+	}
+
 	if tfBinary, ok := fields.GetOk(FieldNameTfBinary); ok {
 		config.TfBinary = tfBinary.(string)
 	}
 
+	if requireApproval, ok := fields.GetOk(FieldNameRequireApproval); ok {
+		config.RequireApproval = requireApproval.(bool)
+	}
+
+	for name, value := range map[string]*int{
+		FieldNameMaxDeletes:                    &config.MaxDeletes,
+		FieldNameMaxReplaces:                   &config.MaxReplaces,
+		FieldNameMaxChanges:                    &config.MaxChanges,
+		FieldNameDestructiveRequiredSignatures: &config.DestructiveRequiredSignatures,
+	} {
+		if v, ok := fields.GetOk(name); ok {
+			*value = v.(int)
+		}
+		if *value < 0 {
+			return logical.ErrorResponse("%q field value should not be negative", name), nil
+		}
+	}
+
+	// Every applied commit has the signatures required by the repository, a lower value would allow any plan
+	if config.DestructiveRequiredSignatures > 0 {
+		repoConfig, err := git_repository.GetConfig(ctx, req.Storage, b.Logger())
+		if err == nil && config.DestructiveRequiredSignatures <= repoConfig.RequiredNumberOfVerifiedSignaturesOnCommit {
+			return logical.ErrorResponse("%q field value should exceed %q of the repository (%d)", FieldNameDestructiveRequiredSignatures,
+				git_repository.FieldNameRequiredNumberOfVerifiedSignaturesOnCommit, repoConfig.RequiredNumberOfVerifiedSignaturesOnCommit), nil
+		}
+	}
+
+	if protectedResources, ok := fields.GetOk(FieldNameProtectedResources); ok {
+		config.ProtectedResources = protectedResources.([]string)
+	}
+	for _, pattern := range config.ProtectedResources {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return logical.ErrorResponse("%q field value %q is invalid: %s", FieldNameProtectedResources, pattern, err), nil
+		}
+	}
+
 	// Validate TfBinary if it was provided or set
 	if config.TfBinary != "" {
 		if err := validateTfBinary(config.TfBinary); err != nil {
@@ -220,6 +329,18 @@ Terraform configuration of the gitops_terraform backend.
 	configureHelpDesc = `
 The terraform configuration is used to specify the path to Terraform files within the git repository.
 
+A commit which changes no file under terraform_path and watch_paths is
+finished without running terraform.
+
+With require_approval the plan of a new commit is stored under plans/<commit>
+and applied only after it is approved.
+
+The blast-radius guard blocks a plan which deletes more than max_deletes,
+replaces more than max_replaces or changes more than max_changes resources, or
+deletes or replaces a resource matching protected_resources. Such a plan is
+applied only if the commit has destructive_required_signatures verified
+signatures.
+
 This is terraform configuration for the gitops_terraform plugin.
 `
 )
@@ -1,23 +1,32 @@
 package terraform
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/logical"
 	trdlGit "github.com/trublast/vault-plugin-gitops-terraform/pkg/git"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
 )
 
 const (
 	// StorageKeyTerraformState is the key for storing terraform state in storage
 	StorageKeyTerraformState = "terraform_state"
+
+	// CancelGracePeriod is the time terraform is given to stop after SIGINT before it is killed
+	CancelGracePeriod = 30 * time.Second
 )
 
 // CLIConfig contains configuration for terraform CLI execution
@@ -29,10 +38,196 @@ type CLIConfig struct {
 	TfBinary       string
 	Storage        logical.Storage
 	Logger         hclog.Logger
+	// OnPhase, if set, is called when init, plan or apply is started
+	OnPhase func(phase string)
+	// CheckPlan, if set, is called before apply, the plan is not applied if it returns an error
+	CheckPlan func(plan *PlanArtifact) error
+	// Targets limit plan to the resource addresses, RefreshOnly plans only the update of the state
+	Targets     []string
+	RefreshOnly bool
+}
+
+// reportPhase notifies the caller about the started phase
+func (c CLIConfig) reportPhase(phase string) {
+	if c.OnPhase != nil {
+		c.OnPhase(phase)
+	}
 }
 
-// ApplyTerraformFromRepo extracts terraform files from git repository and applies them using Terraform CLI
-func ApplyTerraformFromRepo(ctx context.Context, gitRepo *git.Repository, config CLIConfig) error {
+// planArgs returns arguments of terraform plan
+func (c CLIConfig) planArgs(args ...string) []string {
+	for _, target := range c.Targets {
+		args = append(args, "-target="+target)
+	}
+	if c.RefreshOnly {
+		args = append(args, "-refresh-only")
+	}
+	return append(args, "-out=tfplan")
+}
+
+// checkPlan allows any plan if CheckPlan is not set
+func (c CLIConfig) checkPlan(plan *PlanArtifact) error {
+	if c.CheckPlan == nil {
+		return nil
+	}
+	return c.CheckPlan(plan)
+}
+
+// ApplyTerraformFromRepo extracts terraform files from git repository and applies them using Terraform CLI.
+// Returns resources changed by the plan, also if apply failed
+func ApplyTerraformFromRepo(ctx context.Context, gitRepo *git.Repository, config CLIConfig) ([]runs.ResourceChange, error) {
+	var changes []runs.ResourceChange
+	err := withTerraformWorkDir(ctx, gitRepo, config, func(tfDir string) error {
+		// Run terraform init
+		config.reportPhase(runs.PhaseInit)
+		if err := runTerraformInit(ctx, tfDir, config); err != nil {
+			return fmt.Errorf("terraform init: %w", err)
+		}
+
+		// Run terraform plan
+		config.reportPhase(runs.PhasePlan)
+		if err := runTerraformPlan(ctx, tfDir, config); err != nil {
+			return fmt.Errorf("terraform plan: %w", err)
+		}
+
+		// Without a plan check the summary of the plan is informational, apply does not depend on it
+		planJSON, err := runTerraformShowPlan(ctx, tfDir, config)
+		if err == nil {
+			changes, err = ParsePlanChanges(planJSON)
+		}
+		if err != nil {
+			if config.CheckPlan != nil {
+				return fmt.Errorf("terraform show: %w", err)
+			}
+			config.Logger.Warn(fmt.Sprintf("Unable to get terraform plan changes: %v", err))
+		}
+
+		if err := config.checkPlan(&PlanArtifact{Rendered: planJSON, Changes: changes}); err != nil {
+			return err
+		}
+
+		// Run terraform apply
+		config.reportPhase(runs.PhaseApply)
+		if err := runTerraformApply(ctx, tfDir, config); err != nil {
+			return fmt.Errorf("terraform apply: %w", err)
+		}
+
+		return nil
+	})
+
+	return changes, err
+}
+
+// PlanTerraformFromRepo extracts terraform files from git repository and plans them without applying.
+// Returns resources which would be changed
+func PlanTerraformFromRepo(ctx context.Context, gitRepo *git.Repository, config CLIConfig) ([]runs.ResourceChange, error) {
+	var changes []runs.ResourceChange
+	err := withTerraformWorkDir(ctx, gitRepo, config, func(tfDir string) error {
+		config.reportPhase(runs.PhaseInit)
+		if err := runTerraformInit(ctx, tfDir, config); err != nil {
+			return fmt.Errorf("terraform init: %w", err)
+		}
+
+		config.reportPhase(runs.PhasePlan)
+		hasChanges, err := runTerraformPlanDetailed(ctx, tfDir, config)
+		if err != nil {
+			return fmt.Errorf("terraform plan: %w", err)
+		}
+		if !hasChanges {
+			return nil
+		}
+
+		planJSON, err := runTerraformShowPlan(ctx, tfDir, config)
+		if err != nil {
+			return fmt.Errorf("terraform show: %w", err)
+		}
+
+		if changes, err = ParsePlanChanges(planJSON); err != nil {
+			return fmt.Errorf("parsing plan: %w", err)
+		}
+		return nil
+	})
+
+	return changes, err
+}
+
+// PlanArtifact is the saved plan of the commit which can be applied later
+type PlanArtifact struct {
+	// Binary is the plan file created by `terraform plan -out`
+	Binary []byte
+	// Rendered is `terraform show -json` output of the plan file
+	Rendered []byte
+	Changes  []runs.ResourceChange
+}
+
+// CreatePlanFromRepo extracts terraform files from git repository and saves the plan without applying it.
+// The plan is checked by CheckPlan when it is created and again when it is applied
+func CreatePlanFromRepo(ctx context.Context, gitRepo *git.Repository, config CLIConfig) (*PlanArtifact, error) {
+	artifact := &PlanArtifact{}
+	err := withTerraformWorkDir(ctx, gitRepo, config, func(tfDir string) error {
+		config.reportPhase(runs.PhaseInit)
+		if err := runTerraformInit(ctx, tfDir, config); err != nil {
+			return fmt.Errorf("terraform init: %w", err)
+		}
+
+		config.reportPhase(runs.PhasePlan)
+		if err := runTerraformPlan(ctx, tfDir, config); err != nil {
+			return fmt.Errorf("terraform plan: %w", err)
+		}
+
+		binary, err := os.ReadFile(filepath.Join(tfDir, "tfplan"))
+		if err != nil {
+			return fmt.Errorf("reading plan file: %w", err)
+		}
+		artifact.Binary = binary
+
+		if artifact.Rendered, err = runTerraformShowPlan(ctx, tfDir, config); err != nil {
+			return fmt.Errorf("terraform show: %w", err)
+		}
+
+		if artifact.Changes, err = ParsePlanChanges(artifact.Rendered); err != nil {
+			return fmt.Errorf("parsing plan: %w", err)
+		}
+
+		// A plan rejected by policies is not stored for approval
+		return config.checkPlan(artifact)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return artifact, nil
+}
+
+// ApplyPlanFromRepo extracts terraform files from git repository and applies the saved plan file.
+// Terraform refuses to apply the plan if the state was changed since the plan was created
+func ApplyPlanFromRepo(ctx context.Context, gitRepo *git.Repository, config CLIConfig, plan *PlanArtifact) error {
+	if err := config.checkPlan(plan); err != nil {
+		return err
+	}
+
+	return withTerraformWorkDir(ctx, gitRepo, config, func(tfDir string) error {
+		config.reportPhase(runs.PhaseInit)
+		if err := runTerraformInit(ctx, tfDir, config); err != nil {
+			return fmt.Errorf("terraform init: %w", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(tfDir, "tfplan"), plan.Binary, 0600); err != nil {
+			return fmt.Errorf("writing plan file: %w", err)
+		}
+
+		config.reportPhase(runs.PhaseApply)
+		if err := runTerraformApply(ctx, tfDir, config); err != nil {
+			return fmt.Errorf("terraform apply: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// withTerraformWorkDir extracts terraform files into a temporary directory with the stored state,
+// calls fn in the terraform directory and saves the state back
+func withTerraformWorkDir(ctx context.Context, gitRepo *git.Repository, config CLIConfig, fn func(tfDir string) error) error {
 	// Create temporary directory for terraform files
 	tmpDir, err := os.MkdirTemp("", "vault-plugin-terraform-*")
 	if err != nil {
@@ -45,7 +240,8 @@ func ApplyTerraformFromRepo(ctx context.Context, gitRepo *git.Repository, config
 		// Save state before removing directory
 		statePath := filepath.Join(tmpDir, config.TfPath, "terraform.tfstate")
 		if stateData, readErr := os.ReadFile(statePath); readErr == nil && len(stateData) > 0 {
-			if saveErr := saveTerraformState(ctx, stateData, config); saveErr != nil {
+			// State must be saved even if the run is cancelled
+			if saveErr := saveTerraformState(context.WithoutCancel(ctx), stateData, config); saveErr != nil {
 				config.Logger.Warn(fmt.Sprintf("Failed to save terraform state: %v", saveErr))
 			}
 		}
@@ -76,22 +272,7 @@ func ApplyTerraformFromRepo(ctx context.Context, gitRepo *git.Repository, config
 		return fmt.Errorf("loading terraform state: %w", err)
 	}
 
-	// Run terraform init
-	if err := runTerraformInit(ctx, tfDir, config); err != nil {
-		return fmt.Errorf("terraform init: %w", err)
-	}
-
-	// Run terraform plan
-	if err := runTerraformPlan(ctx, tfDir, config); err != nil {
-		return fmt.Errorf("terraform plan: %w", err)
-	}
-
-	// Run terraform apply
-	if err := runTerraformApply(ctx, tfDir, config); err != nil {
-		return fmt.Errorf("terraform apply: %w", err)
-	}
-
-	return nil
+	return fn(tfDir)
 }
 
 // extractTerraformFiles extracts .tf and .hcl files from git repository to temporary directory
@@ -179,11 +360,21 @@ func setupTerraformConfigFile(workDir string, cmd *exec.Cmd) {
 	}
 }
 
+// setupGracefulCancel makes cancellation of the context interrupt terraform, so it can
+// release the lock and write the state, and kill it only after CancelGracePeriod
+func setupGracefulCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = CancelGracePeriod
+}
+
 // runTerraformInit runs terraform init
 func runTerraformInit(ctx context.Context, workDir string, config CLIConfig) error {
 	tfBinary := getTfBinary(config)
 	cmd := exec.CommandContext(ctx, tfBinary, "init", "-no-color", "-input=false")
 	cmd.Dir = workDir
+	setupGracefulCancel(cmd)
 	cmd.Stdout = io.Discard
 
 	// Copy existing environment variables
@@ -214,8 +405,9 @@ func runTerraformInit(ctx context.Context, workDir string, config CLIConfig) err
 // runTerraformPlan runs terraform plan
 func runTerraformPlan(ctx context.Context, workDir string, config CLIConfig) error {
 	tfBinary := getTfBinary(config)
-	cmd := exec.CommandContext(ctx, tfBinary, "plan", "-no-color", "-input=false", "-out=tfplan")
+	cmd := exec.CommandContext(ctx, tfBinary, config.planArgs("plan", "-no-color", "-input=false")...)
 	cmd.Dir = workDir
+	setupGracefulCancel(cmd)
 	cmd.Stdout = io.Discard
 
 	// Copy existing environment variables
@@ -252,12 +444,97 @@ func runTerraformPlan(ctx context.Context, workDir string, config CLIConfig) err
 	return nil
 }
 
+// runTerraformPlanDetailed runs terraform plan with -detailed-exitcode, returns true if there are changes
+func runTerraformPlanDetailed(ctx context.Context, workDir string, config CLIConfig) (bool, error) {
+	tfBinary := getTfBinary(config)
+	cmd := exec.CommandContext(ctx, tfBinary, config.planArgs("plan", "-no-color", "-input=false", "-detailed-exitcode")...)
+	cmd.Dir = workDir
+	setupGracefulCancel(cmd)
+	cmd.Stdout = io.Discard
+
+	// Copy existing environment variables
+	cmd.Env = os.Environ()
+	if config.VaultAddr != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("VAULT_ADDR=%s", config.VaultAddr))
+	}
+	if config.VaultToken != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("VAULT_TOKEN=%s", config.VaultToken))
+	}
+	if config.VaultNamespace != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("VAULT_NAMESPACE=%s", config.VaultNamespace))
+	}
+
+	// Setup terraform config file if exists
+	setupTerraformConfigFile(workDir, cmd)
+
+	cmd.Env = append(cmd.Env, "TF_IN_AUTOMATION=true")
+
+	// Capture stderr to get error details
+	var stderrBuf strings.Builder
+	cmd.Stderr = &stderrBuf
+
+	config.Logger.Info("Running terraform plan -detailed-exitcode")
+	err := cmd.Run()
+
+	// Exit code 2 means succeeded with non-empty diff
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+		config.Logger.Info("Terraform plan completed, changes found")
+		return true, nil
+	}
+
+	if err != nil {
+		stderr := strings.TrimSpace(stderrBuf.String())
+		if stderr != "" {
+			return false, fmt.Errorf("terraform plan failed: %s", stderr)
+		}
+		return false, fmt.Errorf("terraform plan failed: %w", err)
+	}
+
+	config.Logger.Info("Terraform plan completed, no changes")
+	return false, nil
+}
+
+// runTerraformShowPlan returns JSON representation of the plan file
+func runTerraformShowPlan(ctx context.Context, workDir string, config CLIConfig) ([]byte, error) {
+	tfBinary := getTfBinary(config)
+	cmd := exec.CommandContext(ctx, tfBinary, "show", "-no-color", "-json", "tfplan")
+	cmd.Dir = workDir
+	setupGracefulCancel(cmd)
+
+	// Copy existing environment variables
+	cmd.Env = os.Environ()
+
+	// Setup terraform config file if exists
+	setupTerraformConfigFile(workDir, cmd)
+
+	cmd.Env = append(cmd.Env, "TF_IN_AUTOMATION=true")
+
+	var stdoutBuf bytes.Buffer
+	cmd.Stdout = &stdoutBuf
+
+	// Capture stderr to get error details
+	var stderrBuf strings.Builder
+	cmd.Stderr = &stderrBuf
+
+	if err := cmd.Run(); err != nil {
+		stderr := strings.TrimSpace(stderrBuf.String())
+		if stderr != "" {
+			return nil, fmt.Errorf("terraform show failed: %s", stderr)
+		}
+		return nil, fmt.Errorf("terraform show failed: %w", err)
+	}
+
+	return stdoutBuf.Bytes(), nil
+}
+
 // runTerraformApply runs terraform apply with the plan file and returns the state
 // State is returned even if apply failed, so it can be saved for debugging/recovery
 func runTerraformApply(ctx context.Context, workDir string, config CLIConfig) error {
 	tfBinary := getTfBinary(config)
 	cmd := exec.CommandContext(ctx, tfBinary, "apply", "-no-color", "-input=false", "-auto-approve", "tfplan")
 	cmd.Dir = workDir
+	setupGracefulCancel(cmd)
 	cmd.Stdout = io.Discard
 
 	// Copy existing environment variables
@@ -320,6 +597,33 @@ func loadTerraformState(ctx context.Context, workDir string, config CLIConfig) e
 	return nil
 }
 
+// StateChecksum returns sha256 of terraform state in storage, empty string if there is no state
+func StateChecksum(ctx context.Context, storage logical.Storage) (string, error) {
+	entry, err := storage.Get(ctx, StorageKeyTerraformState)
+	if err != nil {
+		return "", fmt.Errorf("getting terraform state from storage: %w", err)
+	}
+	if entry == nil || len(entry.Value) == 0 {
+		return "", nil
+	}
+
+	sum := sha256.Sum256(entry.Value)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// StateSize returns size of terraform state in storage in bytes
+func StateSize(ctx context.Context, storage logical.Storage) (int, error) {
+	entry, err := storage.Get(ctx, StorageKeyTerraformState)
+	if err != nil {
+		return 0, fmt.Errorf("getting terraform state from storage: %w", err)
+	}
+	if entry == nil {
+		return 0, nil
+	}
+
+	return len(entry.Value), nil
+}
+
 // saveTerraformState saves terraform state to storage
 func saveTerraformState(ctx context.Context, state []byte, config CLIConfig) error {
 	if config.Storage == nil {
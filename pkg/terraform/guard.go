@@ -0,0 +1,57 @@
+package terraform
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+// BlastRadiusError is returned when the plan exceeds blast-radius thresholds
+// and the commit does not have destructive_required_signatures
+type BlastRadiusError struct {
+	Violations []string
+}
+
+func (e *BlastRadiusError) Error() string {
+	return fmt.Sprintf("blocked by blast-radius guard: %s", strings.Join(e.Violations, "; "))
+}
+
+// HasBlastRadiusGuard returns true if any blast-radius threshold is set
+func (c *Configuration) HasBlastRadiusGuard() bool {
+	return c.MaxDeletes > 0 || c.MaxReplaces > 0 || c.MaxChanges > 0 || len(c.ProtectedResources) > 0
+}
+
+// BlastRadiusViolations returns the thresholds exceeded by the plan, empty if the plan is within them
+func (c *Configuration) BlastRadiusViolations(changes []runs.ResourceChange) []string {
+	summary := runs.NewPlanSummary(changes)
+
+	var violations []string
+	if c.MaxDeletes > 0 && summary.Delete > c.MaxDeletes {
+		violations = append(violations, fmt.Sprintf("%d resources to delete exceed %s=%d", summary.Delete, FieldNameMaxDeletes, c.MaxDeletes))
+	}
+	if c.MaxReplaces > 0 && summary.Replace > c.MaxReplaces {
+		violations = append(violations, fmt.Sprintf("%d resources to replace exceed %s=%d", summary.Replace, FieldNameMaxReplaces, c.MaxReplaces))
+	}
+	total := summary.Create + summary.Update + summary.Delete + summary.Replace
+	if c.MaxChanges > 0 && total > c.MaxChanges {
+		violations = append(violations, fmt.Sprintf("%d changed resources exceed %s=%d", total, FieldNameMaxChanges, c.MaxChanges))
+	}
+
+	for _, change := range changes {
+		action := runs.ChangeAction(change.Actions)
+		if action != runs.ActionDelete && action != runs.ActionReplace {
+			continue
+		}
+		for _, pattern := range c.ProtectedResources {
+			// patterns are validated on configuration
+			if matched, _ := path.Match(pattern, change.Address); matched {
+				violations = append(violations, fmt.Sprintf("protected resource %q is going to %s", change.Address, action))
+				break
+			}
+		}
+	}
+
+	return violations
+}
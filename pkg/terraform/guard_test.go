@@ -0,0 +1,55 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+func Test_BlastRadiusViolations(t *testing.T) {
+	changes := []runs.ResourceChange{
+		{Address: "vault_policy.dev", Type: "vault_policy", Actions: []string{"create"}},
+		{Address: "vault_policy.admin", Type: "vault_policy", Actions: []string{"delete"}},
+		{Address: "vault_policy.ops", Type: "vault_policy", Actions: []string{"delete"}},
+		{Address: "vault_auth_backend.oidc", Type: "vault_auth_backend", Actions: []string{"delete", "create"}},
+		{Address: "vault_mount.kv", Type: "vault_mount", Actions: []string{"update"}},
+	}
+
+	tests := []struct {
+		description string
+		config      *Configuration
+		expected    []string
+	}{
+		{
+			description: "no thresholds",
+			config:      &Configuration{},
+		},
+		{
+			description: "within thresholds",
+			config:      &Configuration{MaxDeletes: 2, MaxReplaces: 1, MaxChanges: 5},
+		},
+		{
+			description: "thresholds exceeded",
+			config:      &Configuration{MaxDeletes: 1, MaxReplaces: 1, MaxChanges: 4},
+			expected: []string{
+				"2 resources to delete exceed max_deletes=1",
+				"5 changed resources exceed max_changes=4",
+			},
+		},
+		{
+			description: "protected resources",
+			config:      &Configuration{ProtectedResources: []string{"vault_auth_backend.*", "vault_mount.*"}},
+			expected: []string{
+				`protected resource "vault_auth_backend.oidc" is going to replace`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.config.BlastRadiusViolations(changes))
+		})
+	}
+}
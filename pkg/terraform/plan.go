@@ -0,0 +1,43 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+// planJSON is the part of `terraform show -json` plan representation used by the plugin
+type planJSON struct {
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Type    string `json:"type"`
+		Change  struct {
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+}
+
+// ParsePlanChanges returns resources which the plan changes, no-op and read resources are skipped.
+// The result is not nil for a valid plan
+func ParsePlanChanges(data []byte) ([]runs.ResourceChange, error) {
+	var plan planJSON
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal plan: %w", err)
+	}
+
+	changes := []runs.ResourceChange{}
+	for _, resourceChange := range plan.ResourceChanges {
+		if runs.ChangeAction(resourceChange.Change.Actions) == "" {
+			continue
+		}
+
+		changes = append(changes, runs.ResourceChange{
+			Address: resourceChange.Address,
+			Type:    resourceChange.Type,
+			Actions: resourceChange.Change.Actions,
+		})
+	}
+
+	return changes, nil
+}
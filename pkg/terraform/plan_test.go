@@ -0,0 +1,37 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+func Test_ParsePlanChanges(t *testing.T) {
+	plan := `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "vault_policy.admin", "type": "vault_policy", "change": {"actions": ["update"]}},
+    {"address": "vault_policy.dev", "type": "vault_policy", "change": {"actions": ["no-op"]}},
+    {"address": "data.vault_generic_secret.x", "type": "vault_generic_secret", "change": {"actions": ["read"]}},
+    {"address": "vault_mount.kv", "type": "vault_mount", "change": {"actions": ["delete", "create"]}},
+    {"address": "vault_auth_backend.old", "type": "vault_auth_backend", "change": {"actions": ["delete"]}}
+  ]
+}`
+
+	changes, err := ParsePlanChanges([]byte(plan))
+	require.NoError(t, err)
+	require.Equal(t, []runs.ResourceChange{
+		{Address: "vault_policy.admin", Type: "vault_policy", Actions: []string{"update"}},
+		{Address: "vault_mount.kv", Type: "vault_mount", Actions: []string{"delete", "create"}},
+		{Address: "vault_auth_backend.old", Type: "vault_auth_backend", Actions: []string{"delete"}},
+	}, changes)
+
+	changes, err = ParsePlanChanges([]byte(`{"format_version": "1.2"}`))
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	_, err = ParsePlanChanges([]byte(`not json`))
+	require.Error(t, err)
+}
@@ -0,0 +1,123 @@
+package terraform
+
+// RedactedValue replaces sensitive values of the plan
+const RedactedValue = "(sensitive value)"
+
+// RedactSensitiveValues replaces values marked as sensitive in `terraform show -json` plan representation:
+// changes of resources and outputs, planned values, prior state and values of sensitive variables
+func RedactSensitiveValues(plan map[string]interface{}) {
+	for _, key := range []string{"resource_changes", "resource_drift"} {
+		resourceChanges, _ := plan[key].([]interface{})
+		for _, resourceChange := range resourceChanges {
+			resourceChange, _ := resourceChange.(map[string]interface{})
+			change, _ := resourceChange["change"].(map[string]interface{})
+			redactChange(change)
+		}
+	}
+
+	outputChanges, _ := plan["output_changes"].(map[string]interface{})
+	for _, change := range outputChanges {
+		change, _ := change.(map[string]interface{})
+		redactChange(change)
+	}
+
+	plannedValues, _ := plan["planned_values"].(map[string]interface{})
+	redactValues(plannedValues)
+
+	priorState, _ := plan["prior_state"].(map[string]interface{})
+	priorValues, _ := priorState["values"].(map[string]interface{})
+	redactValues(priorValues)
+
+	configuration, _ := plan["configuration"].(map[string]interface{})
+	rootModule, _ := configuration["root_module"].(map[string]interface{})
+	variableConfigs, _ := rootModule["variables"].(map[string]interface{})
+	variables, _ := plan["variables"].(map[string]interface{})
+	for name, variableConfig := range variableConfigs {
+		variableConfig, _ := variableConfig.(map[string]interface{})
+		variable, _ := variables[name].(map[string]interface{})
+		if sensitive, _ := variableConfig["sensitive"].(bool); sensitive && variable != nil {
+			variable["value"] = RedactedValue
+		}
+	}
+}
+
+// redactChange redacts before and after values of the change by before_sensitive and after_sensitive
+func redactChange(change map[string]interface{}) {
+	if change == nil {
+		return
+	}
+	change["before"] = redactValue(change["before"], change["before_sensitive"])
+	change["after"] = redactValue(change["after"], change["after_sensitive"])
+}
+
+// redactValues redacts values of the module and its child modules, the module is
+// `values` representation of terraform with resources and outputs
+func redactValues(values map[string]interface{}) {
+	if values == nil {
+		return
+	}
+
+	outputs, _ := values["outputs"].(map[string]interface{})
+	for _, output := range outputs {
+		output, _ := output.(map[string]interface{})
+		if sensitive, _ := output["sensitive"].(bool); sensitive {
+			output["value"] = RedactedValue
+		}
+	}
+
+	module, _ := values["root_module"].(map[string]interface{})
+	redactModule(module)
+}
+
+func redactModule(module map[string]interface{}) {
+	if module == nil {
+		return
+	}
+
+	resources, _ := module["resources"].([]interface{})
+	for _, resource := range resources {
+		resource, _ := resource.(map[string]interface{})
+		if resource == nil {
+			continue
+		}
+		resource["values"] = redactValue(resource["values"], resource["sensitive_values"])
+	}
+
+	childModules, _ := module["child_modules"].([]interface{})
+	for _, childModule := range childModules {
+		childModule, _ := childModule.(map[string]interface{})
+		redactModule(childModule)
+	}
+}
+
+// redactValue returns the value with parts redacted where the mask of the same structure is true
+func redactValue(value, mask interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	switch mask := mask.(type) {
+	case bool:
+		if mask {
+			return RedactedValue
+		}
+	case map[string]interface{}:
+		if object, ok := value.(map[string]interface{}); ok {
+			for key, attributeMask := range mask {
+				if attribute, ok := object[key]; ok {
+					object[key] = redactValue(attribute, attributeMask)
+				}
+			}
+		}
+	case []interface{}:
+		if list, ok := value.([]interface{}); ok {
+			for i := range list {
+				if i < len(mask) {
+					list[i] = redactValue(list[i], mask[i])
+				}
+			}
+		}
+	}
+
+	return value
+}
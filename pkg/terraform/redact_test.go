@@ -0,0 +1,109 @@
+package terraform
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RedactSensitiveValues(t *testing.T) {
+	tests := []struct {
+		description string
+		plan        string
+		expected    string
+	}{
+		{
+			description: "sensitive attribute of resource change",
+			plan: `{"resource_changes": [{"address": "vault_generic_secret.app", "change": {
+				"actions": ["update"],
+				"before": {"path": "secret/app", "data_json": "{\"password\":\"old\"}"},
+				"after": {"path": "secret/app", "data_json": "{\"password\":\"new\"}"},
+				"before_sensitive": {"data_json": true},
+				"after_sensitive": {"data_json": true}
+			}}]}`,
+			expected: `{"resource_changes": [{"address": "vault_generic_secret.app", "change": {
+				"actions": ["update"],
+				"before": {"path": "secret/app", "data_json": "(sensitive value)"},
+				"after": {"path": "secret/app", "data_json": "(sensitive value)"},
+				"before_sensitive": {"data_json": true},
+				"after_sensitive": {"data_json": true}
+			}}]}`,
+		},
+		{
+			description: "nested block and list",
+			plan: `{"resource_drift": [{"change": {
+				"before": {"auth": [{"token": "t1", "name": "a"}], "tags": ["x", "y"]},
+				"after": null,
+				"before_sensitive": {"auth": [{"token": true}], "tags": [false, true]},
+				"after_sensitive": false
+			}}]}`,
+			expected: `{"resource_drift": [{"change": {
+				"before": {"auth": [{"token": "(sensitive value)", "name": "a"}], "tags": ["x", "(sensitive value)"]},
+				"after": null,
+				"before_sensitive": {"auth": [{"token": true}], "tags": [false, true]},
+				"after_sensitive": false
+			}}]}`,
+		},
+		{
+			description: "sensitive output change",
+			plan: `{"output_changes": {
+				"token": {"before": null, "after": "s.secret", "after_sensitive": true},
+				"address": {"before": null, "after": "https://vault", "after_sensitive": false}
+			}}`,
+			expected: `{"output_changes": {
+				"token": {"before": null, "after": "(sensitive value)", "after_sensitive": true},
+				"address": {"before": null, "after": "https://vault", "after_sensitive": false}
+			}}`,
+		},
+		{
+			description: "planned values and prior state",
+			plan: `{
+				"planned_values": {
+					"outputs": {"token": {"sensitive": true, "value": "s.secret"}},
+					"root_module": {"child_modules": [{"resources": [
+						{"values": {"password": "p", "name": "n"}, "sensitive_values": {"password": true}}
+					]}]}
+				},
+				"prior_state": {"values": {"root_module": {"resources": [
+					{"values": {"password": "p"}, "sensitive_values": {"password": true}}
+				]}}}
+			}`,
+			expected: `{
+				"planned_values": {
+					"outputs": {"token": {"sensitive": true, "value": "(sensitive value)"}},
+					"root_module": {"child_modules": [{"resources": [
+						{"values": {"password": "(sensitive value)", "name": "n"}, "sensitive_values": {"password": true}}
+					]}]}
+				},
+				"prior_state": {"values": {"root_module": {"resources": [
+					{"values": {"password": "(sensitive value)"}, "sensitive_values": {"password": true}}
+				]}}}
+			}`,
+		},
+		{
+			description: "sensitive variable",
+			plan: `{
+				"variables": {"db_password": {"value": "p"}, "region": {"value": "eu"}},
+				"configuration": {"root_module": {"variables": {"db_password": {"sensitive": true}, "region": {}}}}
+			}`,
+			expected: `{
+				"variables": {"db_password": {"value": "(sensitive value)"}, "region": {"value": "eu"}},
+				"configuration": {"root_module": {"variables": {"db_password": {"sensitive": true}, "region": {}}}}
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			var plan map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.plan), &plan))
+
+			RedactSensitiveValues(plan)
+
+			actual, err := json.Marshal(plan)
+			require.NoError(t, err)
+			require.JSONEq(t, tt.expected, string(actual))
+		})
+	}
+}
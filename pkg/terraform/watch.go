@@ -0,0 +1,31 @@
+package terraform
+
+import (
+	"path"
+	"strings"
+)
+
+// HasRelevantChanges returns true if any of the changed files is under TfPath or one of WatchPaths
+func (c *Configuration) HasRelevantChanges(changedFiles []string) bool {
+	watchPaths := append([]string{c.TfPath}, c.WatchPaths...)
+
+	for _, file := range changedFiles {
+		for _, watchPath := range watchPaths {
+			if isUnderPath(file, watchPath) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isUnderPath returns true if the file is the path or inside it, empty path is the root of the repository
+func isUnderPath(file, dir string) bool {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	if dir == "" {
+		return true
+	}
+
+	return file == dir || strings.HasPrefix(file, dir+"/")
+}
@@ -0,0 +1,56 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_HasRelevantChanges(t *testing.T) {
+	tests := []struct {
+		description  string
+		config       *Configuration
+		changedFiles []string
+		expected     bool
+	}{
+		{
+			description:  "root of the repository",
+			config:       &Configuration{},
+			changedFiles: []string{"app/main.go"},
+			expected:     true,
+		},
+		{
+			description: "no changed files",
+			config:      &Configuration{},
+		},
+		{
+			description:  "file under terraform path",
+			config:       &Configuration{TfPath: "infra/vault"},
+			changedFiles: []string{"app/main.go", "infra/vault/main.tf"},
+			expected:     true,
+		},
+		{
+			description:  "files outside of terraform path",
+			config:       &Configuration{TfPath: "infra/vault"},
+			changedFiles: []string{"app/main.go", "infra/vault-docs/README.md", "infra/main.tf"},
+		},
+		{
+			description:  "file under watch path",
+			config:       &Configuration{TfPath: "infra/vault", WatchPaths: []string{"infra/modules"}},
+			changedFiles: []string{"infra/modules/kv/main.tf"},
+			expected:     true,
+		},
+		{
+			description:  "watched file",
+			config:       &Configuration{TfPath: "infra/vault", WatchPaths: []string{".terraform-version"}},
+			changedFiles: []string{".terraform-version"},
+			expected:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.config.HasRelevantChanges(tt.changedFiles))
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// PrefixedStorage stores keys under the prefix, except shared keys which are stored
+// in the underlying storage as is
+type PrefixedStorage struct {
+	storage logical.Storage
+	view    *logical.StorageView
+	// exact keys, or prefixes if ending with '/'
+	sharedKeys []string
+}
+
+var _ logical.Storage = (*PrefixedStorage)(nil)
+
+func NewPrefixedStorage(storage logical.Storage, prefix string, sharedKeys []string) *PrefixedStorage {
+	return &PrefixedStorage{
+		storage:    storage,
+		view:       logical.NewStorageView(storage, prefix),
+		sharedKeys: sharedKeys,
+	}
+}
+
+func (s *PrefixedStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	return s.target(prefix).List(ctx, prefix)
+}
+
+func (s *PrefixedStorage) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	return s.target(key).Get(ctx, key)
+}
+
+func (s *PrefixedStorage) Put(ctx context.Context, entry *logical.StorageEntry) error {
+	return s.target(entry.Key).Put(ctx, entry)
+}
+
+func (s *PrefixedStorage) Delete(ctx context.Context, key string) error {
+	return s.target(key).Delete(ctx, key)
+}
+
+func (s *PrefixedStorage) target(key string) logical.Storage {
+	for _, shared := range s.sharedKeys {
+		if key == shared || (strings.HasSuffix(shared, "/") && strings.HasPrefix(key, shared)) {
+			return s.storage
+		}
+	}
+	return s.view
+}
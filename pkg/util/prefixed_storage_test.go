@@ -0,0 +1,36 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PrefixedStorage(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+	prefixed := NewPrefixedStorage(storage, "workspaces/a/", []string{"shared", "keys/"})
+
+	require.NoError(t, PutString(ctx, prefixed, "own", "1"))
+	require.NoError(t, PutString(ctx, prefixed, "shared", "2"))
+	require.NoError(t, PutString(ctx, prefixed, "keys/k1", "3"))
+
+	keys, err := storage.List(ctx, "")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"workspaces/", "shared", "keys/"}, keys)
+
+	value, err := GetString(ctx, storage, "workspaces/a/own")
+	require.NoError(t, err)
+	require.Equal(t, "1", value)
+
+	keys, err = prefixed.List(ctx, "keys/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"k1"}, keys)
+
+	require.NoError(t, prefixed.Delete(ctx, "own"))
+	value, err = GetString(ctx, storage, "workspaces/a/own")
+	require.NoError(t, err)
+	require.Equal(t, "", value)
+}
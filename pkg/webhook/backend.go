@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	FieldNameProvider = "provider"
+	FieldNameSecret   = "secret"
+
+	ProviderGitLab = "gitlab"
+
+	// StorageKeyPrefixSecret is the storage prefix of webhook shared secrets, one entry per provider
+	StorageKeyPrefixSecret = "webhook_secret/"
+)
+
+// ProviderRegex matches supported webhook providers in path patterns
+var ProviderRegex = fmt.Sprintf("(?P<%s>%s)", FieldNameProvider, ProviderGitLab)
+
+type Secret struct {
+	Secret string `structs:"secret" json:"secret"`
+}
+
+type backend struct {
+	// just for logger provider
+	baseBackend *framework.Backend
+}
+
+func (b *backend) Logger() hclog.Logger {
+	return b.baseBackend.Logger()
+}
+
+func Paths(baseBackend *framework.Backend) []*framework.Path {
+	b := backend{
+		baseBackend: baseBackend,
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^configure/webhook/" + ProviderRegex + "/?$",
+			Fields: map[string]*framework.FieldSchema{
+				FieldNameProvider: {
+					Type:        framework.TypeString,
+					Description: "Webhook provider: gitlab.",
+				},
+				FieldNameSecret: {
+					Type:        framework.TypeString,
+					Description: "Shared secret configured in the webhook settings of the provider. Required for CREATE, UPDATE.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.CreateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Configure webhook shared secret.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathConfigureCreateOrUpdate,
+					Summary:  "Configure webhook shared secret.",
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathConfigureRead,
+					Summary:  "Check whether webhook is configured.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathConfigureDelete,
+					Summary:  "Delete webhook shared secret.",
+				},
+			},
+			ExistenceCheck:  b.pathConfigExistenceCheck,
+			HelpSynopsis:    configureHelpSyn,
+			HelpDescription: configureHelpDesc,
+		},
+	}
+}
+
+// pathConfigExistenceCheck verifies if the secret exists.
+func (b *backend) pathConfigExistenceCheck(ctx context.Context, req *logical.Request, fields *framework.FieldData) (bool, error) {
+	provider := fields.Get(FieldNameProvider).(string)
+	out, err := req.Storage.Get(ctx, secretStorageKey(provider))
+	if err != nil {
+		return false, fmt.Errorf("existence check failed: %w", err)
+	}
+
+	return out != nil, nil
+}
+
+func (b *backend) pathConfigureCreateOrUpdate(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Webhook configuration started")
+
+	provider := fields.Get(FieldNameProvider).(string)
+	secret := Secret{
+		Secret: fields.Get(FieldNameSecret).(string),
+	}
+
+	if secret.Secret == "" {
+		return logical.ErrorResponse("%q field value should not be empty", FieldNameSecret), nil
+	}
+
+	if err := putSecret(ctx, req.Storage, provider, secret); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathConfigureRead(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Reading webhook configuration")
+
+	provider := fields.Get(FieldNameProvider).(string)
+	secret, err := GetSecret(ctx, req.Storage, provider)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get webhook secret: %s", err), nil
+	}
+	if secret == nil {
+		return nil, nil
+	}
+
+	// Return only provider, not secret
+	return &logical.Response{Data: map[string]interface{}{
+		FieldNameProvider: provider,
+	}}, nil
+}
+
+func (b *backend) pathConfigureDelete(ctx context.Context, req *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Trace("Deleting webhook configuration")
+
+	provider := fields.Get(FieldNameProvider).(string)
+	if err := req.Storage.Delete(ctx, secretStorageKey(provider)); err != nil {
+		return logical.ErrorResponse("Unable to delete webhook secret: %s", err), nil
+	}
+
+	return nil, nil
+}
+
+// GetSecret returns shared secret of provider or nil if webhook for provider is not configured
+func GetSecret(ctx context.Context, storage logical.Storage, provider string) (*Secret, error) {
+	storageEntry, err := storage.Get(ctx, secretStorageKey(provider))
+	if err != nil {
+		return nil, err
+	}
+	if storageEntry == nil {
+		return nil, nil
+	}
+
+	var secret *Secret
+	if err := storageEntry.DecodeJSON(&secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+func putSecret(ctx context.Context, storage logical.Storage, provider string, secret Secret) error {
+	storageEntry, err := logical.StorageEntryJSON(secretStorageKey(provider), secret)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, storageEntry)
+}
+
+func secretStorageKey(provider string) string {
+	return StorageKeyPrefixSecret + provider
+}
+
+const (
+	configureHelpSyn = `
+Webhook configuration of the gitops_terraform backend.
+`
+	configureHelpDesc = `
+Stores the shared secret used to verify push events received on
+webhook/<provider>. The secret is never returned on read.
+
+GitLab sends the secret in the X-Gitlab-Token header. GitHub and Gitea are
+not supported: they only sign the request body, and Vault does not pass the
+raw body to plugins.
+`
+)
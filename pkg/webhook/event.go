@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	headerGitLabEvent = "X-Gitlab-Event"
+	headerGitLabToken = "X-Gitlab-Token"
+)
+
+// PushEvent is the part of a push event payload the plugin is interested in
+type PushEvent struct {
+	Ref    string
+	Commit string
+}
+
+// NotPushEventError is returned for events of other types (ping, tag push, merge request, ...)
+type NotPushEventError struct {
+	Event string
+}
+
+func (e *NotPushEventError) Error() string {
+	return fmt.Sprintf("event %q is not a push event", e.Event)
+}
+
+// Verify checks that the request was sent by the provider which knows the shared secret.
+// Only providers sending the secret in a header are supported: Vault does not pass the raw
+// request body to plugins, so signatures of the body can not be verified
+func Verify(provider string, secret string, headers http.Header) error {
+	switch provider {
+	case ProviderGitLab:
+		token := headers.Get(headerGitLabToken)
+		if token == "" {
+			return fmt.Errorf("header %q is not set", headerGitLabToken)
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			return errors.New("token mismatch")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported provider %q", provider)
+	}
+}
+
+// ParsePushEvent extracts ref and commit from the push event payload
+// Returns NotPushEventError if the event is of other type
+func ParsePushEvent(provider string, headers http.Header, data map[string]interface{}) (*PushEvent, error) {
+	var eventHeader, pushEvent string
+	switch provider {
+	case ProviderGitLab:
+		eventHeader, pushEvent = headerGitLabEvent, "Push Hook"
+		if kind, ok := data["object_kind"].(string); ok && kind != "push" {
+			return nil, &NotPushEventError{Event: kind}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported provider %q", provider)
+	}
+
+	// event header is only available when it is in passthrough_request_headers of the mount
+	if event := headers.Get(eventHeader); event != "" && event != pushEvent {
+		return nil, &NotPushEventError{Event: event}
+	}
+
+	ref, _ := data["ref"].(string)
+	if ref == "" {
+		return nil, &NotPushEventError{Event: "unknown"}
+	}
+
+	commit, _ := data["after"].(string)
+	if checkoutSHA, ok := data["checkout_sha"].(string); ok && checkoutSHA != "" {
+		commit = checkoutSHA
+	}
+
+	return &PushEvent{
+		Ref:    ref,
+		Commit: commit,
+	}, nil
+}
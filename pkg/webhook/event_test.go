@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func header(key, value string) http.Header {
+	h := http.Header{}
+	h.Set(key, value)
+	return h
+}
+
+func Test_Verify(t *testing.T) {
+	type testcase struct {
+		description string
+		provider    string
+		headers     http.Header
+		expectError bool
+	}
+
+	tests := []testcase{
+		{
+			description: "gitlab valid token",
+			provider:    ProviderGitLab,
+			headers:     header(headerGitLabToken, "s3cret"),
+		},
+		{
+			description: "gitlab wrong token",
+			provider:    ProviderGitLab,
+			headers:     header(headerGitLabToken, "wrong"),
+			expectError: true,
+		},
+		{
+			description: "gitlab without token",
+			provider:    ProviderGitLab,
+			headers:     http.Header{},
+			expectError: true,
+		},
+		{
+			description: "github signs the body",
+			provider:    "github",
+			headers:     header("X-Hub-Signature-256", "sha256=0123"),
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := Verify(test.provider, "s3cret", test.headers)
+			if test.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_ParsePushEvent(t *testing.T) {
+	type testcase struct {
+		description   string
+		provider      string
+		headers       http.Header
+		data          map[string]interface{}
+		expectedEvent *PushEvent
+		expectNotPush bool
+	}
+
+	tests := []testcase{
+		{
+			description:   "gitlab push uses checkout_sha",
+			provider:      ProviderGitLab,
+			headers:       header(headerGitLabEvent, "Push Hook"),
+			data:          map[string]interface{}{"object_kind": "push", "ref": "refs/heads/main", "after": "abc", "checkout_sha": "abc"},
+			expectedEvent: &PushEvent{Ref: "refs/heads/main", Commit: "abc"},
+		},
+		{
+			description:   "gitlab tag push",
+			provider:      ProviderGitLab,
+			headers:       http.Header{},
+			data:          map[string]interface{}{"object_kind": "tag_push", "ref": "refs/tags/v1"},
+			expectNotPush: true,
+		},
+		{
+			description:   "gitlab merge request",
+			provider:      ProviderGitLab,
+			headers:       header(headerGitLabEvent, "Merge Request Hook"),
+			data:          map[string]interface{}{"object_kind": "merge_request"},
+			expectNotPush: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			event, err := ParsePushEvent(test.provider, test.headers, test.data)
+			if test.expectNotPush {
+				var notPushEventErr *NotPushEventError
+				require.ErrorAs(t, err, &notPushEventErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedEvent, event)
+		})
+	}
+}
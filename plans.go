@@ -0,0 +1,219 @@
+package gitops_terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/plans"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/terraform"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	fieldNamePlanCommit = "commit"
+)
+
+func plansPaths(b *backend) []*framework.Path {
+	commitField := map[string]*framework.FieldSchema{
+		fieldNamePlanCommit: {
+			Type:        framework.TypeString,
+			Description: "Full hash of the planned commit.",
+		},
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "^plans/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathPlansList,
+					Summary:  "List stored plans.",
+				},
+			},
+			HelpSynopsis:    plansHelpSyn,
+			HelpDescription: plansHelpDesc,
+		},
+		{
+			Pattern: "^plans/" + framework.GenericNameRegex(fieldNamePlanCommit) + "/approve/?$",
+			Fields:  commitField,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathPlanApprove,
+					Summary:  "Approve the plan and apply it.",
+				},
+			},
+			HelpSynopsis:    plansHelpSyn,
+			HelpDescription: plansHelpDesc,
+		},
+		{
+			Pattern: "^plans/" + framework.GenericNameRegex(fieldNamePlanCommit) + "$",
+			Fields:  commitField,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathPlanRead,
+					Summary:  "Read the plan for review.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathPlanDelete,
+					Summary:  "Delete the plan.",
+				},
+			},
+			HelpSynopsis:    plansHelpSyn,
+			HelpDescription: plansHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathPlansList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	commitHashes, err := plans.ListPlans(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to list plans: %s", err), nil
+	}
+
+	keyInfo := make(map[string]interface{}, len(commitHashes))
+	for _, commitHash := range commitHashes {
+		plan, err := plans.GetPlan(ctx, req.Storage, commitHash)
+		if err != nil {
+			return logical.ErrorResponse("Unable to get plan of commit %q: %s", commitHash, err), nil
+		}
+		if plan == nil {
+			continue
+		}
+		keyInfo[commitHash] = map[string]interface{}{
+			"status":     plan.Status,
+			"created_at": plan.CreatedAt.Format(time.RFC3339),
+			"changes":    len(plan.Changes),
+		}
+	}
+
+	return logical.ListResponseWithInfo(commitHashes, keyInfo), nil
+}
+
+func (b *backend) pathPlanRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	plan, err := plans.GetPlan(ctx, req.Storage, data.Get(fieldNamePlanCommit).(string))
+	if err != nil {
+		return logical.ErrorResponse("Unable to get plan: %s", err), nil
+	}
+	if plan == nil {
+		return nil, nil
+	}
+
+	responseData := map[string]interface{}{
+		"commit_hash": plan.CommitHash,
+		"commit_date": plan.CommitDate.Format(time.RFC3339),
+		"status":      plan.Status,
+		"created_at":  plan.CreatedAt.Format(time.RFC3339),
+		"changes":     plan.Changes,
+	}
+	if plan.ApprovedBy != "" {
+		responseData["approved_by"] = plan.ApprovedBy
+		responseData["approved_at"] = plan.ApprovedAt.Format(time.RFC3339)
+	}
+	if !plan.AppliedAt.IsZero() {
+		responseData["applied_at"] = plan.AppliedAt.Format(time.RFC3339)
+	}
+	if len(plan.Rendered) > 0 {
+		var rendered map[string]interface{}
+		if err := json.Unmarshal(plan.Rendered, &rendered); err != nil {
+			return logical.ErrorResponse("Unable to decode plan: %s", err), nil
+		}
+		terraform.RedactSensitiveValues(rendered)
+		responseData["plan"] = rendered
+	}
+
+	return &logical.Response{Data: responseData}, nil
+}
+
+func (b *backend) pathPlanDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := plans.DeletePlan(ctx, req.Storage, data.Get(fieldNamePlanCommit).(string)); err != nil {
+		return logical.ErrorResponse("Unable to delete plan: %s", err), nil
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathPlanApprove(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	commitHash := data.Get(fieldNamePlanCommit).(string)
+
+	plan, err := plans.GetPlan(ctx, req.Storage, commitHash)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get plan: %s", err), nil
+	}
+	if plan == nil {
+		return logical.ErrorResponse("Plan of commit %q not found", commitHash), nil
+	}
+	if plan.Status != plans.StatusPending {
+		return logical.ErrorResponse("Plan of commit %q is %s", commitHash, plan.Status), nil
+	}
+
+	stateChecksum, err := terraform.StateChecksum(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get terraform state: %s", err), nil
+	}
+	if plan.IsStale(stateChecksum) {
+		plan.Expire()
+		if err := plans.PutPlan(ctx, req.Storage, plan); err != nil {
+			return nil, fmt.Errorf("unable to store plan: %w", err)
+		}
+		return logical.ErrorResponse("Plan of commit %q expired: terraform state changed since it was created, the commit is planned again by the next run", commitHash), nil
+	}
+
+	plan.Status = plans.StatusApproved
+	plan.ApprovedBy = req.DisplayName
+	plan.ApprovedAt = systemClock.Now()
+	if err := plans.PutPlan(ctx, req.Storage, plan); err != nil {
+		return nil, fmt.Errorf("unable to store plan: %w", err)
+	}
+
+	b.Logger().Info("Plan approved", "commitHash", commitHash, "approvedBy", req.DisplayName)
+
+	var lastFinishedCommit *LastFinishedCommit
+	if err := util.GetJSON(ctx, req.Storage, storageKeyLastFinishedCommit, &lastFinishedCommit); err != nil {
+		return logical.ErrorResponse("Unable to get commit: %s", err), nil
+	}
+
+	runID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate run id: %w", err)
+	}
+
+	opts := processGitOptions{
+		RunID:     runID,
+		Trigger:   triggerApproval,
+		Workspace: requestWorkspace(data),
+	}
+
+	// The approval is stored, so the plan is applied by the next run anyway
+	if !b.startProcessGit(req.Storage, lastFinishedCommit, opts) {
+		resp := &logical.Response{}
+		resp.AddWarning("GitOps task already in progress, the plan is applied by the next run")
+		return resp, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"run_id": runID,
+		},
+	}, nil
+}
+
+const (
+	plansHelpSyn = `
+Plans of commits waiting for approval.
+`
+	plansHelpDesc = `
+With require_approval in configure/terraform a new signed commit is only
+planned: the plan file and its JSON rendering are stored under plans/<commit>
+for review. Writing to plans/<commit>/approve applies exactly this plan.
+
+A plan expires if terraform state changed since it was created, a newer
+planned commit also expires older plans. An expired commit is planned again
+by the next run.
+`
+)
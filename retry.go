@@ -0,0 +1,58 @@
+package gitops_terraform
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/retry"
+)
+
+func retryPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "^runs/retry/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathRunsRetryWrite,
+					Summary:  "Release the quarantined commit and process it again.",
+				},
+			},
+			HelpSynopsis:    retryHelpSyn,
+			HelpDescription: retryHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathRunsRetryWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	failure, err := retry.GetFailure(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to get commit failure: %s", err), nil
+	}
+	if failure == nil || !failure.Quarantined {
+		return logical.ErrorResponse("No quarantined commit"), nil
+	}
+
+	if err := retry.ClearFailure(ctx, req.Storage); err != nil {
+		return nil, fmt.Errorf("unable to clear commit failure: %w", err)
+	}
+
+	workspace := requestWorkspace(data)
+	b.Logger().Info("Quarantined commit released", "commitHash", failure.CommitHash, "workspace", workspace, "requestedBy", req.DisplayName)
+
+	return b.startManualRun(ctx, req.Storage, workspace, false)
+}
+
+const (
+	retryHelpSyn = `
+Retry the quarantined commit.
+`
+	retryHelpDesc = `
+Forgets failed attempts of the quarantined commit and starts a run at once.
+If another run is in progress the commit is processed by the next run.
+The quarantined commit of a named workspace is released at
+workspaces/<workspace>/runs/retry.
+`
+)
@@ -0,0 +1,189 @@
+package gitops_terraform
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/plans"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	fieldNameRollbackCommit = "commit"
+
+	storageKeyRollbackOverride = "rollback_override"
+	storageKeyPendingRollback  = "pending_rollback"
+)
+
+// RollbackOverride is stored after a rollback: periodic runs do not reapply the branch HEAD
+// and only apply signed commits newer than BranchHeadHash
+type RollbackOverride struct {
+	CommitHash     string    `json:"commit_hash"`
+	CommitDate     time.Time `json:"commit_date"`
+	BranchHeadHash string    `json:"branch_head_hash"`
+	BranchHeadDate time.Time `json:"branch_head_date"`
+	RequestedBy    string    `json:"requested_by"`
+	RequestedAt    time.Time `json:"requested_at"`
+}
+
+func rollbackPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "^rollback/?$",
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameRollbackCommit: {
+					Type:        framework.TypeString,
+					Description: "Full hash of the commit to apply. Required.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathRollbackWrite,
+					Summary:  "Apply an earlier signed commit of the branch.",
+				},
+			},
+			HelpSynopsis:    rollbackHelpSyn,
+			HelpDescription: rollbackHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathRollbackWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	commitHash := data.Get(fieldNameRollbackCommit).(string)
+	if commitHash == "" {
+		return logical.ErrorResponse("%q field value should not be empty", fieldNameRollbackCommit), nil
+	}
+
+	workspace := requestWorkspace(data)
+	storage := req.Storage
+
+	// Do not clone the repository if the commit can not be applied now anyway
+	if atomic.LoadUint32(b.processGitCASGuard(workspace)) != 0 {
+		return logical.ErrorResponse("GitOps task already in progress"), nil
+	}
+
+	commitInfo, branchHead, err := git_repository.GitService(ctx, storage, b.Logger()).VerifyBranchCommit(commitHash)
+	if err != nil {
+		return logical.ErrorResponse("Unable to roll back to commit %q: %s", commitHash, err), nil
+	}
+
+	var lastFinishedCommit *LastFinishedCommit
+	if err := util.GetJSON(ctx, storage, storageKeyLastFinishedCommit, &lastFinishedCommit); err != nil {
+		return logical.ErrorResponse("Unable to get commit: %s", err), nil
+	}
+
+	runID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate run id: %w", err)
+	}
+
+	opts := processGitOptions{
+		RunID:     runID,
+		Trigger:   triggerRollback,
+		Workspace: workspace,
+		Rollback: &RollbackOverride{
+			CommitHash:     commitInfo.CommitHash,
+			CommitDate:     commitInfo.CommitDate,
+			BranchHeadHash: branchHead.CommitHash,
+			BranchHeadDate: branchHead.CommitDate,
+			RequestedBy:    req.DisplayName,
+			RequestedAt:    systemClock.Now(),
+		},
+	}
+
+	if !b.startProcessGit(storage, lastFinishedCommit, opts) {
+		return logical.ErrorResponse("GitOps task already in progress"), nil
+	}
+
+	b.Logger().Info("Rollback started", "commitHash", commitHash, "runID", runID, "workspace", workspace, "requestedBy", req.DisplayName)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"run_id": runID,
+		},
+	}, nil
+}
+
+// getRollbackOverride returns nil if the last applied commit is not a rollback
+func getRollbackOverride(ctx context.Context, storage logical.Storage) (*RollbackOverride, error) {
+	var override *RollbackOverride
+	if err := util.GetJSON(ctx, storage, storageKeyRollbackOverride, &override); err != nil {
+		return nil, err
+	}
+	return override, nil
+}
+
+// pendingRollback returns the rollback waiting for approval of its plan. The rollback is dropped
+// when its plan is deleted or was already applied, whatever the result of the apply was
+func pendingRollback(ctx context.Context, storage logical.Storage) (*RollbackOverride, error) {
+	var rollback *RollbackOverride
+	if err := util.GetJSON(ctx, storage, storageKeyPendingRollback, &rollback); err != nil {
+		return nil, err
+	}
+	if rollback == nil {
+		return nil, nil
+	}
+
+	plan, err := plans.GetPlan(ctx, storage, rollback.CommitHash)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get plan: %w", err)
+	}
+	// An expired plan is planned again
+	if plan != nil && plan.Status != plans.StatusApplied {
+		return rollback, nil
+	}
+
+	if err := storage.Delete(ctx, storageKeyPendingRollback); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// updateRollbackOverride is called when the commit is applied: a rollback stores the override,
+// a newer commit removes it
+func updateRollbackOverride(ctx context.Context, storage logical.Storage, rollback *RollbackOverride, commitInfo *git_repository.CommitInfo) error {
+	if rollback != nil {
+		if err := storage.Delete(ctx, storageKeyPendingRollback); err != nil {
+			return err
+		}
+		return util.PutJSON(ctx, storage, storageKeyRollbackOverride, rollback)
+	}
+
+	override, err := getRollbackOverride(ctx, storage)
+	if err != nil {
+		return err
+	}
+
+	// Forced re-apply of the rolled back commit keeps the override
+	if override == nil || override.CommitHash == commitInfo.CommitHash {
+		return nil
+	}
+
+	return storage.Delete(ctx, storageKeyRollbackOverride)
+}
+
+const (
+	rollbackHelpSyn = `
+Roll back to an earlier signed commit.
+`
+	rollbackHelpDesc = `
+Verifies that the commit has the required number of verified signatures and
+belongs to the history of the configured branch, then applies it in a new run.
+
+With require_approval in configure/terraform the commit is only planned, the
+rollback is applied when plans/<commit>/approve is written. Until then runs of
+the workspace plan the rollback instead of new commits, deleting the plan
+discards the rollback.
+
+After a successful rollback periodic runs do not reapply the branch HEAD: only
+a signed commit newer than the HEAD at the time of the rollback is applied.
+A named workspace is rolled back at workspaces/<workspace>/rollback.
+`
+)
@@ -0,0 +1,161 @@
+package gitops_terraform
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/directives"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/metrics"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/policies"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+)
+
+// runTracker records progress of the current processGit run into the run history
+// Storage errors are only logged: history must not break the run itself
+type runTracker struct {
+	// ctx of storage operations, not cancelled with the run
+	ctx     context.Context
+	runCtx  context.Context
+	storage logical.Storage
+	logger  hclog.Logger
+	run     *runs.Run
+	metrics *metrics.Collector
+	// phaseStartedAt is the time the current phase was reached
+	phaseStartedAt time.Time
+}
+
+func (b *backend) newRunTracker(ctx context.Context, storage logical.Storage, opts processGitOptions) *runTracker {
+	t := &runTracker{
+		ctx:     context.WithoutCancel(ctx),
+		runCtx:  ctx,
+		storage: storage,
+		logger:  b.Logger(),
+		run: &runs.Run{
+			ID:        opts.RunID,
+			Workspace: opts.Workspace,
+			Trigger:   opts.Trigger,
+			Status:    runs.StatusRunning,
+			Phase:     runs.PhaseClone,
+			StartedAt: systemClock.Now(),
+		},
+		metrics: b.metrics,
+	}
+	t.phaseStartedAt = t.run.StartedAt
+	t.save()
+	return t
+}
+
+// SetPhase records the phase the run has reached
+func (t *runTracker) SetPhase(phase string) {
+	if phase != t.run.Phase {
+		t.finishPhase()
+	}
+	t.run.Phase = phase
+	t.save()
+}
+
+// SetCommit records the commit being processed
+// A commit skipped or planned by directive does not end a sequential run, so the status is running again
+func (t *runTracker) SetCommit(commitInfo *git_repository.CommitInfo) {
+	t.run.CommitHash = commitInfo.CommitHash
+	t.run.CommitDate = commitInfo.CommitDate
+	t.run.Status = runs.StatusRunning
+	t.run.SkipReason = ""
+	t.run.Directives = nil
+	t.save()
+}
+
+// SetCommitDetails records the author and signers of the commit being processed
+func (t *runTracker) SetCommitDetails(author string, signers []string) {
+	t.run.CommitAuthor = author
+	t.run.Signers = signers
+	t.save()
+}
+
+// SetDirectives records the processing options given by the commit message
+func (t *runTracker) SetDirectives(options *directives.Options) {
+	t.run.Directives = options
+	t.save()
+}
+
+// SetDriftChanges records resources changed outside of the repository
+func (t *runTracker) SetDriftChanges(changes []runs.ResourceChange) {
+	t.run.DriftChanges = changes
+	t.save()
+}
+
+// SetPlanSummary records what the plan of the commit changes, nil means there is no plan
+func (t *runTracker) SetPlanSummary(changes []runs.ResourceChange) {
+	if changes == nil {
+		return
+	}
+	t.run.PlanSummary = runs.NewPlanSummary(changes)
+	t.save()
+}
+
+// SetPolicyResult records deny and warn messages of Rego policies
+func (t *runTracker) SetPolicyResult(result *policies.Result) {
+	t.run.PolicyDenials = result.Deny
+	t.run.PolicyWarnings = result.Warn
+	t.save()
+}
+
+// SetAdmissionDecision records the decision of the admission webhook
+func (t *runTracker) SetAdmissionDecision(decision *runs.AdmissionDecision) {
+	t.run.AdmissionDecision = decision
+	t.save()
+}
+
+// SetStatus sets the final status of a run which ends without applying the commit
+func (t *runTracker) SetStatus(status string) {
+	t.run.Status = status
+	t.save()
+}
+
+// SetSkipped sets the final status of a run which finished the commit without running terraform
+func (t *runTracker) SetSkipped(reason string) {
+	t.run.Status = runs.StatusSkipped
+	t.run.SkipReason = reason
+	t.save()
+}
+
+// Finish records the result of the run
+func (t *runTracker) Finish(err error) {
+	t.run.FinishedAt = systemClock.Now()
+	switch {
+	case err != nil && t.runCtx.Err() != nil:
+		t.run.Status = runs.StatusCancelled
+		t.run.Error = err.Error()
+	case err != nil:
+		t.run.Status = runs.StatusFailed
+		t.run.Error = err.Error()
+	case t.run.Status != runs.StatusRunning:
+		// final status is already set
+	case t.run.CommitHash == "":
+		t.run.Status = runs.StatusNoNewCommit
+	default:
+		t.run.Status = runs.StatusSucceeded
+	}
+	t.save()
+
+	t.finishPhase()
+	t.metrics.RunFinished(t.run.Workspace, t.run.Status)
+}
+
+// finishPhase observes time spent in the current phase
+func (t *runTracker) finishPhase() {
+	now := systemClock.Now()
+	t.metrics.PhaseFinished(t.run.Workspace, t.run.Phase, now.Sub(t.phaseStartedAt))
+	t.phaseStartedAt = now
+}
+
+func (t *runTracker) save() {
+	if err := runs.PutRun(t.ctx, t.storage, t.run); err != nil {
+		t.logger.Warn(fmt.Sprintf("Unable to save run %q: %v", t.run.ID, err))
+	}
+}
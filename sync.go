@@ -0,0 +1,95 @@
+package gitops_terraform
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+)
+
+const (
+	fieldNameSyncForce = "force"
+)
+
+func syncPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "^sync/?$",
+			Fields: map[string]*framework.FieldSchema{
+				fieldNameSyncForce: {
+					Type:        framework.TypeBool,
+					Default:     false,
+					Description: "Re-apply the last finished commit if no newer signed commit found.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathSyncWrite,
+					Summary:  "Trigger processing of the git repository immediately.",
+				},
+			},
+			HelpSynopsis:    syncHelpSyn,
+			HelpDescription: syncHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathSyncWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.Logger().Debug("Manual sync requested")
+
+	return b.startManualRun(ctx, req.Storage, requestWorkspace(data), data.Get(fieldNameSyncForce).(bool))
+}
+
+// startManualRun starts processing of the git repository and responds with the run id
+// Storage must be the storage of the workspace
+func (b *backend) startManualRun(ctx context.Context, storage logical.Storage, workspace string, force bool) (*logical.Response, error) {
+	if _, err := git_repository.GetConfig(ctx, storage, b.Logger()); err != nil {
+		return logical.ErrorResponse("Unable to get git repository configuration: %s", err), nil
+	}
+
+	var lastFinishedCommit *LastFinishedCommit
+	if err := util.GetJSON(ctx, storage, storageKeyLastFinishedCommit, &lastFinishedCommit); err != nil {
+		return logical.ErrorResponse("Unable to get commit: %s", err), nil
+	}
+
+	runID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate run id: %w", err)
+	}
+
+	opts := processGitOptions{
+		RunID:     runID,
+		Trigger:   triggerManual,
+		Workspace: workspace,
+		Force:     force,
+	}
+
+	if !b.startProcessGit(storage, lastFinishedCommit, opts) {
+		return logical.ErrorResponse("GitOps task already in progress"), nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"run_id": runID,
+		},
+	}, nil
+}
+
+const (
+	syncHelpSyn = `
+Trigger processing of the git repository.
+`
+	syncHelpDesc = `
+Schedules an immediate run regardless of git_poll_period. The run is skipped
+if another run is already in progress. With force=true the last finished
+commit is applied again when no newer signed commit is found.
+
+The progress of the run can be read at runs/<run_id>. A named workspace is
+synced at workspaces/<workspace>/sync.
+`
+)
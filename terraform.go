@@ -2,48 +2,212 @@ package gitops_terraform
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
-	gitHTTP "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/hashicorp/vault/sdk/logical"
 
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/admission"
 	trdlGit "github.com/trublast/vault-plugin-gitops-terraform/pkg/git"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/pgp"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/plans"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/policies"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/resource_policy"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/terraform"
 	"github.com/trublast/vault-plugin-gitops-terraform/pkg/vault_client"
 )
 
 // processCommit aim action with retries
-func (b *backend) processCommit(ctx context.Context, storage logical.Storage, hashCommit string) error {
+// tracker records the phases (clone, init, plan, apply) and policy results of the run
+// Returns resources changed by the plan of the commit, also if apply failed
+func (b *backend) processCommit(ctx context.Context, storage logical.Storage, hashCommit string, tracker *runTracker) ([]runs.ResourceChange, error) {
 	b.Logger().Debug(fmt.Sprintf("Processing commit: %q", hashCommit))
 
+	gitRepo, terraformConfig, err := b.prepareCommit(ctx, storage, hashCommit, tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := terraform.ApplyTerraformFromRepo(ctx, gitRepo, terraformConfig)
+	if err != nil {
+		return changes, fmt.Errorf("unable to apply terraform configuration: %w", err)
+	}
+
+	// Cleanup: memory storage will be garbage collected when gitRepo goes out of scope
+	// Explicitly set to nil to help GC
+	gitRepo = nil
+
+	// lastFinishedCommit will be saved by caller
+	return changes, nil
+}
+
+// planCommit plans the commit without applying it and returns resources which would be changed
+func (b *backend) planCommit(ctx context.Context, storage logical.Storage, hashCommit string, tracker *runTracker) ([]runs.ResourceChange, error) {
+	b.Logger().Debug(fmt.Sprintf("Planning commit: %q", hashCommit))
+
+	gitRepo, terraformConfig, err := b.prepareCommit(ctx, storage, hashCommit, tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := terraform.PlanTerraformFromRepo(ctx, gitRepo, terraformConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to plan terraform configuration: %w", err)
+	}
+
+	return changes, nil
+}
+
+// activePlan returns the pending or approved plan of the commit, a new pending plan is created and stored
+// if the commit has no plan yet. A plan created against another terraform state is planned again
+func (b *backend) activePlan(ctx context.Context, storage logical.Storage, commitInfo *git_repository.CommitInfo, tracker *runTracker) (*plans.Plan, error) {
+	plan, err := plans.GetPlan(ctx, storage, commitInfo.CommitHash)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get plan: %w", err)
+	}
+
+	stateChecksum, err := terraform.StateChecksum(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if plan != nil && plan.IsActive() {
+		if !plan.IsStale(stateChecksum) {
+			return plan, nil
+		}
+		b.Logger().Info("Terraform state changed since the plan was created, planning again", "commitHash", commitInfo.CommitHash)
+	}
+
+	b.Logger().Debug(fmt.Sprintf("Planning commit for approval: %q", commitInfo.CommitHash))
+
+	gitRepo, terraformConfig, err := b.prepareCommit(ctx, storage, commitInfo.CommitHash, tracker)
+	if err != nil {
+		return nil, err
+	}
+
+	artifact, err := terraform.CreatePlanFromRepo(ctx, gitRepo, terraformConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to plan terraform configuration: %w", err)
+	}
+
+	if err := plans.ExpireActivePlans(ctx, storage, commitInfo.CommitHash); err != nil {
+		return nil, fmt.Errorf("unable to expire previous plans: %w", err)
+	}
+
+	plan = &plans.Plan{
+		CommitHash:    commitInfo.CommitHash,
+		CommitDate:    commitInfo.CommitDate,
+		Status:        plans.StatusPending,
+		CreatedAt:     systemClock.Now(),
+		StateChecksum: stateChecksum,
+		Changes:       artifact.Changes,
+		Binary:        artifact.Binary,
+		Rendered:      artifact.Rendered,
+	}
+	if err := plans.PutPlan(ctx, storage, plan); err != nil {
+		return nil, fmt.Errorf("unable to store plan: %w", err)
+	}
+
+	b.Logger().Info("Commit is planned, waiting for approval", "commitHash", commitInfo.CommitHash, "changedResources", len(plan.Changes))
+
+	return plan, nil
+}
+
+// applyPlan applies the stored plan, the plan can not be applied again whatever the result is
+func (b *backend) applyPlan(ctx context.Context, storage logical.Storage, plan *plans.Plan, tracker *runTracker) error {
+	b.Logger().Debug(fmt.Sprintf("Applying approved plan of commit: %q", plan.CommitHash))
+
+	gitRepo, terraformConfig, err := b.prepareCommit(ctx, storage, plan.CommitHash, tracker)
+	if err != nil {
+		return err
+	}
+
+	applyErr := terraform.ApplyPlanFromRepo(ctx, gitRepo, terraformConfig, &terraform.PlanArtifact{
+		Binary:   plan.Binary,
+		Rendered: plan.Rendered,
+		Changes:  plan.Changes,
+	})
+	var guardErr *terraform.BlastRadiusError
+	switch {
+	case errors.As(applyErr, &guardErr):
+		// The plan is kept approved and applied when the commit gets enough signatures
+		return applyErr
+	case applyErr != nil:
+		plan.Expire()
+	default:
+		plan.Status = plans.StatusApplied
+		plan.AppliedAt = systemClock.Now()
+		plan.Binary = nil
+	}
+
+	// Plan status must be stored even if the run is cancelled
+	if err := plans.PutPlan(context.WithoutCancel(ctx), storage, plan); err != nil {
+		b.Logger().Warn(fmt.Sprintf("Unable to store plan: %v", err))
+	}
+
+	if applyErr != nil {
+		return fmt.Errorf("unable to apply terraform plan: %w", applyErr)
+	}
+
+	return nil
+}
+
+// prepareCommit clones the repository at the commit and returns terraform CLI configuration for it
+func (b *backend) prepareCommit(ctx context.Context, storage logical.Storage, hashCommit string, tracker *runTracker) (*git.Repository, terraform.CLIConfig, error) {
 	// Get git repository configuration
 	config, err := git_repository.GetConfig(ctx, storage, b.Logger())
 	if err != nil {
-		return fmt.Errorf("unable to get git repository configuration: %w", err)
+		return nil, terraform.CLIConfig{}, fmt.Errorf("unable to get git repository configuration: %w", err)
 	}
 
 	// Get vault client configuration
 	vaultConfig, err := vault_client.GetConfig(ctx, storage)
 	if err != nil {
-		return fmt.Errorf("unable to get vault configuration: %w", err)
+		return nil, terraform.CLIConfig{}, fmt.Errorf("unable to get vault configuration: %w", err)
 	}
 
 	// Get terraform configuration
 	tfConfig, err := terraform.GetConfig(ctx, storage)
 	if err != nil {
-		return fmt.Errorf("unable to get terraform configuration: %w", err)
+		return nil, terraform.CLIConfig{}, fmt.Errorf("unable to get terraform configuration: %w", err)
+	}
+
+	// Get resource policy, nil if it is not configured
+	policy, err := resource_policy.GetConfig(ctx, storage)
+	if err != nil {
+		return nil, terraform.CLIConfig{}, fmt.Errorf("unable to get resource policy: %w", err)
+	}
+
+	// Compile Rego policies, nil if there are no policies
+	evaluator, err := policies.NewEvaluator(ctx, storage)
+	if err != nil {
+		return nil, terraform.CLIConfig{}, fmt.Errorf("unable to get Rego policies: %w", err)
+	}
+
+	// Get admission webhook configuration, nil if it is not configured
+	admissionConfig, err := admission.GetConfig(ctx, storage)
+	if err != nil {
+		return nil, terraform.CLIConfig{}, fmt.Errorf("unable to get admission webhook configuration: %w", err)
 	}
 
 	// Clone repository and checkout to specific commit
+	tracker.SetPhase(runs.PhaseClone)
 	gitRepo, err := b.cloneRepositoryAtCommit(ctx, storage, config, hashCommit)
 	if err != nil {
-		return fmt.Errorf("unable to clone repository at commit %q: %w", hashCommit, err)
+		return nil, terraform.CLIConfig{}, fmt.Errorf("unable to clone repository at commit %q: %w", hashCommit, err)
+	}
+
+	// Author and signers are recorded for notifications and the admission webhook
+	if err := b.recordCommitDetails(ctx, storage, gitRepo, hashCommit, tracker); err != nil {
+		return nil, terraform.CLIConfig{}, err
 	}
 
-	// Apply terraform configuration from repository using CLI
 	terraformConfig := terraform.CLIConfig{
 		VaultAddr:      vaultConfig.VaultAddr,
 		VaultToken:     vaultConfig.VaultToken,
@@ -52,20 +216,172 @@ func (b *backend) processCommit(ctx context.Context, storage logical.Storage, ha
 		TfBinary:       tfConfig.TfBinary,
 		Storage:        storage,
 		Logger:         b.Logger(),
+		OnPhase:        tracker.SetPhase,
 	}
 
-	if err := terraform.ApplyTerraformFromRepo(ctx, gitRepo, terraformConfig); err != nil {
-		return fmt.Errorf("unable to apply terraform configuration: %w", err)
+	// Gitops-Target and Gitops-Refresh-Only directives of the commit limit the plan
+	if options := tracker.run.Directives; options != nil {
+		terraformConfig.Targets = options.Targets
+		terraformConfig.RefreshOnly = options.RefreshOnly
 	}
 
-	// Cleanup: memory storage will be garbage collected when gitRepo goes out of scope
-	// Explicitly set to nil to help GC
-	gitRepo = nil
+	// Every plan is checked against the resource policy, then Rego policies, then the blast-radius guard,
+	// the admission webhook is asked last
+	var planChecks []func(plan *terraform.PlanArtifact) error
+	if policy != nil {
+		planChecks = append(planChecks, func(plan *terraform.PlanArtifact) error {
+			return policy.Check(plan.Changes)
+		})
+	}
+	if evaluator != nil {
+		planChecks = append(planChecks, b.regoPolicyCheck(ctx, evaluator, gitRepo, hashCommit, tracker))
+	}
+	if tfConfig.HasBlastRadiusGuard() {
+		planChecks = append(planChecks, b.blastRadiusGuard(ctx, storage, gitRepo, hashCommit, tfConfig, config.RequiredNumberOfVerifiedSignaturesOnCommit))
+	}
+	if admissionConfig != nil {
+		planChecks = append(planChecks, b.admissionCheck(ctx, admissionConfig, hashCommit, tracker))
+	}
+	if len(planChecks) > 0 {
+		terraformConfig.CheckPlan = func(plan *terraform.PlanArtifact) error {
+			for _, check := range planChecks {
+				if err := check(plan); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	return gitRepo, terraformConfig, nil
+}
+
+// blastRadiusGuard returns the plan check which allows a plan exceeding blast-radius thresholds
+// only if the commit has destructive_required_signatures verified signatures
+// Every applied commit has requiredSignatures, so destructive_required_signatures not exceeding it allows nothing more
+func (b *backend) blastRadiusGuard(ctx context.Context, storage logical.Storage, gitRepo *git.Repository, hashCommit string, tfConfig *terraform.Configuration, requiredSignatures int) func(plan *terraform.PlanArtifact) error {
+	return func(plan *terraform.PlanArtifact) error {
+		violations := tfConfig.BlastRadiusViolations(plan.Changes)
+		if len(violations) == 0 {
+			return nil
+		}
+
+		switch {
+		case tfConfig.DestructiveRequiredSignatures == 0:
+			// always blocked
+		case tfConfig.DestructiveRequiredSignatures <= requiredSignatures:
+			b.Logger().Warn("destructive_required_signatures does not exceed required signatures of the repository, plan is blocked",
+				"destructiveRequiredSignatures", tfConfig.DestructiveRequiredSignatures, "requiredSignatures", requiredSignatures)
+			violations = append(violations, fmt.Sprintf("%s %d should exceed %s %d", terraform.FieldNameDestructiveRequiredSignatures,
+				tfConfig.DestructiveRequiredSignatures, git_repository.FieldNameRequiredNumberOfVerifiedSignaturesOnCommit, requiredSignatures))
+		default:
+			trustedPGPPublicKeys, err := pgp.GetTrustedPGPPublicKeys(ctx, storage)
+			if err != nil {
+				return fmt.Errorf("unable to get trusted public keys: %w", err)
+			}
+
+			err = trdlGit.VerifyCommitSignatures(gitRepo, hashCommit, trustedPGPPublicKeys, tfConfig.DestructiveRequiredSignatures, b.Logger())
+			var notEnoughErr *trdlGit.NotEnoughVerifiedPGPSignaturesError
+			switch {
+			case err == nil:
+				b.Logger().Info("Plan exceeds blast-radius thresholds, allowed by signatures", "commitHash", hashCommit, "violations", violations)
+				return nil
+			case errors.As(err, &notEnoughErr):
+				violations = append(violations, fmt.Sprintf("%d verified signatures required", tfConfig.DestructiveRequiredSignatures))
+			default:
+				return fmt.Errorf("commit %q signatures: %w", hashCommit, err)
+			}
+		}
 
-	// Return nil on success - lastFinishedCommit will be saved by caller
+		return &terraform.BlastRadiusError{Violations: violations}
+	}
+}
+
+// regoPolicyCheck returns the plan check which evaluates Rego policies against the plan and the commit,
+// results are recorded in the run
+func (b *backend) regoPolicyCheck(ctx context.Context, evaluator *policies.Evaluator, gitRepo *git.Repository, hashCommit string, tracker *runTracker) func(plan *terraform.PlanArtifact) error {
+	return func(plan *terraform.PlanArtifact) error {
+		var planDocument interface{}
+		if err := json.Unmarshal(plan.Rendered, &planDocument); err != nil {
+			return fmt.Errorf("unable to unmarshal plan: %w", err)
+		}
+
+		commit, err := gitRepo.CommitObject(plumbing.NewHash(hashCommit))
+		if err != nil {
+			return fmt.Errorf("unable to get commit %q object: %w", hashCommit, err)
+		}
+
+		result, err := evaluator.Evaluate(ctx, map[string]interface{}{
+			"plan": planDocument,
+			"commit": map[string]interface{}{
+				"hash":         hashCommit,
+				"date":         commit.Committer.When.Format(time.RFC3339),
+				"author":       commit.Author.Name,
+				"author_email": commit.Author.Email,
+				"message":      commit.Message,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		tracker.SetPolicyResult(result)
+
+		if len(result.Warn) > 0 {
+			b.Logger().Warn("Rego policies warn about the plan", "commitHash", hashCommit, "warnings", result.Warn)
+		}
+		if len(result.Deny) > 0 {
+			return &policies.DeniedError{Denials: result.Deny}
+		}
+		return nil
+	}
+}
+
+// recordCommitDetails records the author of the commit and names of trusted keys which signed it
+func (b *backend) recordCommitDetails(ctx context.Context, storage logical.Storage, gitRepo *git.Repository, hashCommit string, tracker *runTracker) error {
+	commit, err := gitRepo.CommitObject(plumbing.NewHash(hashCommit))
+	if err != nil {
+		return fmt.Errorf("unable to get commit %q object: %w", hashCommit, err)
+	}
+
+	trustedPGPPublicKeys, err := pgp.GetTrustedPGPPublicKeysByName(ctx, storage)
+	if err != nil {
+		return fmt.Errorf("unable to get trusted public keys: %w", err)
+	}
+
+	signers, err := trdlGit.CommitSigners(gitRepo, hashCommit, trustedPGPPublicKeys, b.Logger())
+	if err != nil {
+		return fmt.Errorf("commit %q signatures: %w", hashCommit, err)
+	}
+
+	tracker.SetCommitDetails(fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email), signers)
 	return nil
 }
 
+// admissionCheck returns the plan check which sends the plan to the admission webhook,
+// the decision is recorded in the run
+func (b *backend) admissionCheck(ctx context.Context, config *admission.Configuration, hashCommit string, tracker *runTracker) func(plan *terraform.PlanArtifact) error {
+	return func(plan *terraform.PlanArtifact) error {
+		decision := admission.Review(ctx, config, &admission.Request{
+			Workspace:  tracker.run.Workspace,
+			CommitHash: hashCommit,
+			Signers:    tracker.run.Signers,
+			Plan:       plan.Rendered,
+		}, systemClock.Now())
+		tracker.SetAdmissionDecision(decision)
+
+		switch {
+		case decision.Error != "" && decision.Allowed:
+			b.Logger().Warn("Admission webhook failed, plan is allowed by fail_open", "commitHash", hashCommit, "err", decision.Error)
+			return nil
+		case decision.Allowed:
+			b.Logger().Info("Plan is allowed by admission webhook", "commitHash", hashCommit, "message", decision.Message)
+			return nil
+		default:
+			return &admission.DeniedError{Decision: decision}
+		}
+	}
+}
+
 // cloneRepositoryAtCommit clones repository and checks out to specific commit
 func (b *backend) cloneRepositoryAtCommit(ctx context.Context, storage logical.Storage, config *git_repository.Configuration, commitHash string) (*git.Repository, error) {
 	gitCredentials, err := trdlGit.GetGitCredential(ctx, storage)
@@ -76,11 +392,8 @@ func (b *backend) cloneRepositoryAtCommit(ctx context.Context, storage logical.S
 	var cloneOptions trdlGit.CloneOptions
 	{
 		cloneOptions.BranchName = config.GitBranch
-		if gitCredentials != nil && gitCredentials.Username != "" && gitCredentials.Password != "" {
-			cloneOptions.Auth = &gitHTTP.BasicAuth{
-				Username: gitCredentials.Username,
-				Password: gitCredentials.Password,
-			}
+		if cloneOptions.Auth, err = gitCredentials.AuthMethod(); err != nil {
+			return nil, fmt.Errorf("unable to get Git credentials: %w", err)
 		}
 		if config.GitCACertificate != "" {
 			cloneOptions.CABundle = []byte(config.GitCACertificate)
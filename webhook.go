@@ -0,0 +1,161 @@
+package gitops_terraform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/webhook"
+)
+
+const (
+	storageKeyLastWebhookEvent = "last_webhook_event"
+)
+
+// WebhookEvent is the result of handling the last received push event
+type WebhookEvent struct {
+	Provider   string    `json:"provider"`
+	Ref        string    `json:"ref"`
+	Commit     string    `json:"commit"`
+	ReceivedAt time.Time `json:"received_at"`
+	Accepted   bool      `json:"accepted"`
+	Reason     string    `json:"reason,omitempty"`
+	RunID      string    `json:"run_id,omitempty"`
+}
+
+func webhookPaths(b *backend) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "^webhook/" + webhook.ProviderRegex + "/?$",
+			Fields: map[string]*framework.FieldSchema{
+				webhook.FieldNameProvider: {
+					Type:        framework.TypeString,
+					Description: "Webhook provider: gitlab.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathWebhookWrite,
+					Summary:  "Receive push event from git provider.",
+				},
+			},
+			HelpSynopsis:    webhookHelpSyn,
+			HelpDescription: webhookHelpDesc,
+		},
+	}
+}
+
+func (b *backend) pathWebhookWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	provider := data.Get(webhook.FieldNameProvider).(string)
+	b.Logger().Debug("Webhook received", "provider", provider)
+
+	secret, err := webhook.GetSecret(ctx, req.Storage, provider)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get webhook secret: %w", err)
+	}
+	if secret == nil {
+		return logical.ErrorResponse("webhook for %q is not configured", provider), nil
+	}
+
+	headers := http.Header(req.Headers)
+	if err := webhook.Verify(provider, secret.Secret, headers); err != nil {
+		b.Logger().Warn("Webhook verification failed", "provider", provider, "error", err)
+		return nil, logical.ErrPermissionDenied
+	}
+
+	event := &WebhookEvent{
+		Provider:   provider,
+		ReceivedAt: systemClock.Now(),
+	}
+
+	pushEvent, err := webhook.ParsePushEvent(provider, headers, req.Data)
+	if err != nil {
+		var notPushEventErr *webhook.NotPushEventError
+		if !errors.As(err, &notPushEventErr) {
+			return logical.ErrorResponse("Unable to parse push event: %s", err), nil
+		}
+		event.Reason = err.Error()
+		return b.storeWebhookEvent(ctx, req.Storage, event)
+	}
+	event.Ref = pushEvent.Ref
+	event.Commit = pushEvent.Commit
+
+	config, err := git_repository.GetConfig(ctx, req.Storage, b.Logger())
+	if err != nil {
+		return logical.ErrorResponse("Unable to get git repository configuration: %s", err), nil
+	}
+
+	if pushEvent.Ref != "refs/heads/"+config.GitBranch {
+		event.Reason = fmt.Sprintf("ref %q does not match branch %q", pushEvent.Ref, config.GitBranch)
+		return b.storeWebhookEvent(ctx, req.Storage, event)
+	}
+
+	var lastFinishedCommit *LastFinishedCommit
+	if err := util.GetJSON(ctx, req.Storage, storageKeyLastFinishedCommit, &lastFinishedCommit); err != nil {
+		return nil, fmt.Errorf("unable to get last finished commit: %w", err)
+	}
+
+	runID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate run id: %w", err)
+	}
+
+	if !b.startProcessGit(req.Storage, lastFinishedCommit, processGitOptions{RunID: runID, Trigger: triggerWebhook, Workspace: requestWorkspace(data)}) {
+		event.Reason = "GitOps task already in progress"
+		return b.storeWebhookEvent(ctx, req.Storage, event)
+	}
+
+	event.Accepted = true
+	event.RunID = runID
+	return b.storeWebhookEvent(ctx, req.Storage, event)
+}
+
+// storeWebhookEvent saves the event to be shown by status and returns it as the response
+func (b *backend) storeWebhookEvent(ctx context.Context, storage logical.Storage, event *WebhookEvent) (*logical.Response, error) {
+	if !event.Accepted {
+		b.Logger().Info("Webhook event ignored", "provider", event.Provider, "ref", event.Ref, "reason", event.Reason)
+	}
+
+	if err := util.PutJSON(ctx, storage, storageKeyLastWebhookEvent, event); err != nil {
+		return nil, fmt.Errorf("unable to store webhook event: %w", err)
+	}
+
+	return &logical.Response{Data: webhookEventToMap(event)}, nil
+}
+
+func webhookEventToMap(event *WebhookEvent) map[string]interface{} {
+	return map[string]interface{}{
+		"provider":    event.Provider,
+		"ref":         event.Ref,
+		"commit":      event.Commit,
+		"received_at": event.ReceivedAt.Format(time.RFC3339),
+		"accepted":    event.Accepted,
+		"reason":      event.Reason,
+		"run_id":      event.RunID,
+	}
+}
+
+const (
+	webhookHelpSyn = `
+Receive push events from GitLab.
+`
+	webhookHelpDesc = `
+The endpoint does not require a Vault token. Requests are verified with the
+shared secret configured at configure/webhook/<provider>, so the headers the
+provider sends (X-Gitlab-Token and X-Gitlab-Event) must be allowed by
+passthrough_request_headers of the mount.
+
+A push to the configured branch immediately starts processing of the git
+repository. Push events of a named workspace are received at
+workspaces/<workspace>/webhook/<provider>. Other events are ignored, the reason is reported in the response
+and as last_webhook_event by the status endpoint.
+`
+)
@@ -0,0 +1,99 @@
+package gitops_terraform
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_pathWebhookWrite(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	logicalBackend, err := Factory(ctx, logical.TestBackendConfig())
+	require.NoError(t, err)
+	b := logicalBackend.(*backend)
+
+	handle := func(operation logical.Operation, path string, headers http.Header, body string) (*logical.Response, error) {
+		// Vault decodes the JSON body into the request data and passes only passthrough headers
+		var data map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(body), &data))
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: operation,
+			Path:      path,
+			Headers:   headers,
+			Data:      data,
+			Storage:   storage,
+		})
+	}
+
+	resp, err := handle(logical.CreateOperation, "configure/git_repository", nil, `{"git_repo_url": "https://gitlab.example.com/infra/vault.git", "git_branch_name": "main"}`)
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), resp.Error())
+	resp, err = handle(logical.CreateOperation, "configure/webhook/gitlab", nil, `{"secret": "s3cret"}`)
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), resp.Error())
+
+	pushEvent := `{"object_kind": "push", "ref": "refs/heads/feature", "after": "abc", "checkout_sha": "abc"}`
+	tests := []struct {
+		description    string
+		path           string
+		headers        http.Header
+		body           string
+		expectedError  error
+		expectedReason string
+	}{
+		{
+			description:    "push to other branch",
+			path:           "webhook/gitlab",
+			headers:        http.Header{"X-Gitlab-Token": {"s3cret"}, "X-Gitlab-Event": {"Push Hook"}},
+			body:           pushEvent,
+			expectedReason: `ref "refs/heads/feature" does not match branch "main"`,
+		},
+		{
+			description:    "tag push",
+			path:           "webhook/gitlab",
+			headers:        http.Header{"X-Gitlab-Token": {"s3cret"}, "X-Gitlab-Event": {"Tag Push Hook"}},
+			body:           `{"object_kind": "tag_push", "ref": "refs/tags/v1"}`,
+			expectedReason: `event "tag_push" is not a push event`,
+		},
+		{
+			description:   "wrong token",
+			path:          "webhook/gitlab",
+			headers:       http.Header{"X-Gitlab-Token": {"wrong"}},
+			body:          pushEvent,
+			expectedError: logical.ErrPermissionDenied,
+		},
+		{
+			description:   "token header is not passed through",
+			path:          "webhook/gitlab",
+			body:          pushEvent,
+			expectedError: logical.ErrPermissionDenied,
+		},
+		{
+			description:   "provider signing the body",
+			path:          "webhook/github",
+			headers:       http.Header{"X-Hub-Signature-256": {"sha256=0123"}},
+			body:          `{"ref": "refs/heads/main", "after": "abc"}`,
+			expectedError: logical.ErrUnsupportedPath,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			resp, err := handle(logical.UpdateOperation, tt.path, tt.headers, tt.body)
+			if tt.expectedError != nil {
+				require.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.False(t, resp.IsError(), resp.Error())
+			require.Equal(t, false, resp.Data["accepted"])
+			require.Equal(t, tt.expectedReason, resp.Data["reason"])
+		})
+	}
+}
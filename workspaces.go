@@ -0,0 +1,195 @@
+package gitops_terraform
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/admission"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/directives"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/drift"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/git_repository"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/notifications"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/pgp"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/policies"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/resource_policy"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/retry"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/runs"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/schedule"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/terraform"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/util"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/vault_client"
+	"github.com/trublast/vault-plugin-gitops-terraform/pkg/webhook"
+)
+
+const (
+	fieldNameWorkspace = "workspace"
+
+	storageKeyPrefixWorkspace = "workspaces/"
+)
+
+// sharedStorageKeys are common for all workspaces of the mount,
+// everything else (configuration of repository and terraform, state, commits) is kept per workspace
+var sharedStorageKeys = []string{
+	vault_client.StorageKeyConfiguration,
+	pgp.StorageKeyPrefixTrustedPGPPublicKey,
+	git.StorageKeyConfigurationGitCredential,
+	webhook.StorageKeyPrefixSecret,
+	retry.StorageKeyConfiguration,
+	schedule.StorageKeyConfiguration,
+	drift.StorageKeyConfiguration,
+	resource_policy.StorageKeyConfiguration,
+	policies.StorageKeyPrefixPolicy,
+	admission.StorageKeyConfiguration,
+	directives.StorageKeyConfiguration,
+	notifications.StorageKeyPrefixChannel,
+	notifications.StorageKeyPrefixDelivery,
+	runs.StorageKeyConfiguration,
+	runs.StorageKeyPrefixRun,
+	storageKeyPauseState,
+}
+
+// workspaceStorage returns storage of the named workspace, the mount storage for the default one
+func workspaceStorage(storage logical.Storage, workspace string) logical.Storage {
+	if workspace == "" {
+		return storage
+	}
+	return util.NewPrefixedStorage(storage, storageKeyPrefixWorkspace+workspace+"/", sharedStorageKeys)
+}
+
+// listWorkspaces returns names of workspaces with configured git repository
+func listWorkspaces(ctx context.Context, storage logical.Storage) ([]string, error) {
+	keys, err := storage.List(ctx, storageKeyPrefixWorkspace)
+	if err != nil {
+		return nil, err
+	}
+
+	var workspaces []string
+	for _, key := range keys {
+		workspace := strings.TrimSuffix(key, "/")
+		entry, err := workspaceStorage(storage, workspace).Get(ctx, git_repository.StorageKeyConfiguration)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get git repository configuration of workspace %q: %w", workspace, err)
+		}
+		if entry != nil {
+			workspaces = append(workspaces, workspace)
+		}
+	}
+
+	return workspaces, nil
+}
+
+func workspacePaths(b *backend, baseBackend *framework.Backend) []*framework.Path {
+	paths := []*framework.Path{
+		{
+			Pattern: "^workspaces/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.pathWorkspacesList,
+					Summary:  "List workspaces.",
+				},
+			},
+			HelpSynopsis:    workspacesHelpSyn,
+			HelpDescription: workspacesHelpDesc,
+		},
+	}
+
+	for _, path := range framework.PathAppend(
+		git_repository.Paths(baseBackend),
+		terraform.Paths(baseBackend),
+		statusPaths(b),
+		plansPaths(b),
+		syncPaths(b),
+		webhookPaths(b),
+		retryPaths(b),
+		rollbackPaths(b),
+		cancelPaths(b),
+	) {
+		paths = append(paths, inWorkspacePath(path))
+	}
+
+	return paths
+}
+
+func (b *backend) pathWorkspacesList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	workspaces, err := listWorkspaces(ctx, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("Unable to list workspaces: %s", err), nil
+	}
+
+	return logical.ListResponse(workspaces), nil
+}
+
+// inWorkspacePath serves the path under workspaces/<workspace>/ with the storage of the workspace
+func inWorkspacePath(path *framework.Path) *framework.Path {
+	workspacePath := *path
+	workspacePath.Pattern = "^workspaces/" + framework.GenericNameRegex(fieldNameWorkspace) + "/" + strings.TrimPrefix(path.Pattern, "^")
+
+	workspacePath.Fields = map[string]*framework.FieldSchema{
+		fieldNameWorkspace: {
+			Type:        framework.TypeString,
+			Description: "Workspace name.",
+		},
+	}
+	for name, field := range path.Fields {
+		workspacePath.Fields[name] = field
+	}
+
+	workspacePath.Operations = map[logical.Operation]framework.OperationHandler{}
+	for operation, handler := range path.Operations {
+		pathOperation, ok := handler.(*framework.PathOperation)
+		if !ok {
+			continue
+		}
+		workspaceOperation := *pathOperation
+		workspaceOperation.Callback = func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+			defer withWorkspaceStorage(req, data)()
+			return pathOperation.Callback(ctx, req, data)
+		}
+		workspacePath.Operations[operation] = &workspaceOperation
+	}
+
+	if path.ExistenceCheck != nil {
+		workspacePath.ExistenceCheck = func(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+			defer withWorkspaceStorage(req, data)()
+			return path.ExistenceCheck(ctx, req, data)
+		}
+	}
+
+	return &workspacePath
+}
+
+// requestWorkspace returns the workspace of the path, empty for the default workspace
+func requestWorkspace(data *framework.FieldData) string {
+	if _, ok := data.Schema[fieldNameWorkspace]; !ok {
+		return ""
+	}
+	return data.Get(fieldNameWorkspace).(string)
+}
+
+// withWorkspaceStorage replaces storage of the request, the returned function restores it
+func withWorkspaceStorage(req *logical.Request, data *framework.FieldData) func() {
+	storage := req.Storage
+	req.Storage = workspaceStorage(storage, requestWorkspace(data))
+	return func() {
+		req.Storage = storage
+	}
+}
+
+const (
+	workspacesHelpSyn = `
+Named workspaces of the mount.
+`
+	workspacesHelpDesc = `
+Each workspace has its own git repository, terraform configuration, state and
+status under workspaces/<workspace>/, and is processed by the periodic function
+independently with its own poll period. Trusted PGP keys, git credentials, the
+Vault client and policies (schedule, retry, resource and Rego policies, run history, pause) are shared by
+all workspaces of the mount. A workspace exists while its git repository is
+configured.
+`
+)